@@ -1,9 +1,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
 	"time"
 
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/agukrapo/simpler-mock-server/internal/latency"
+	"github.com/agukrapo/simpler-mock-server/internal/mime"
+	"github.com/agukrapo/simpler-mock-server/internal/throttle"
 	"github.com/caarlos0/env/v10"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -36,9 +44,73 @@ type config struct {
 	ResponsesDir  string            `env:"RESPONSES_DIR" envDefault:"./.sms_responses"`
 	Ext2MIMEType  map[string]string `env:"EXTENSION_MIME_TYPE_MAP"`
 	Method2Status map[string]int    `env:"METHOD_STATUS_MAP" envDefault:"DELETE:202,GET:200,PATCH:204,POST:201,PUT:204"`
+
+	SequenceEnd filesystem.SequenceEnd `env:"SEQUENCE_END" envDefault:"repeat"`
+	AdminPrefix string                 `env:"ADMIN_PREFIX" envDefault:"/__sms/"`
+	JournalSize int                    `env:"JOURNAL_SIZE" envDefault:"1000"`
+
+	RecordUpstream *url.URL `env:"RECORD_UPSTREAM"`
+	ProxyUpstream  *url.URL `env:"PROXY_UPSTREAM"`
+
+	ReadOnly     bool   `env:"READ_ONLY"`
+	NotFoundFile string `env:"NOT_FOUND_FILE"`
+	ScratchDir   string `env:"SCRATCH_DIR"`
+
+	NotAcceptable bool        `env:"NOT_ACCEPTABLE"`
+	MIMETypeOrder []mime.Type `env:"MIME_TYPE_ORDER"`
+
+	Latency  latency.Distribution `env:"LATENCY"`
+	Throttle *throttle.Rate       `env:"THROTTLE"`
+
+	TLSCert       string   `env:"TLS_CERT"`
+	TLSKey        string   `env:"TLS_KEY"`
+	TLSSelfSigned bool     `env:"TLS_SELF_SIGNED"`
+	TLSHosts      []string `env:"TLS_HOSTS"`
+	TLSCAFile     string   `env:"TLS_CA_FILE" envDefault:"./sms-ca.pem"`
+
+	TLSClientCA   string      `env:"TLS_CLIENT_CA"`
+	TLSClientAuth *clientAuth `env:"TLS_CLIENT_AUTH"`
 }
 
 func parseConfig() (*config, error) {
 	var cfg config
-	return &cfg, env.Parse(&cfg)
+	opts := env.Options{FuncMap: map[reflect.Type]env.ParserFunc{
+		reflect.TypeFor[latency.Distribution](): func(v string) (interface{}, error) {
+			return latency.Parse(v)
+		},
+	}}
+
+	if err := env.ParseWithOptions(&cfg, opts); err != nil {
+		return nil, err
+	}
+
+	if strings.Trim(cfg.AdminPrefix, "/") == "" {
+		return nil, fmt.Errorf("invalid ADMIN_PREFIX %q, the admin API can't take over every path", cfg.AdminPrefix)
+	}
+
+	if cfg.RecordUpstream != nil && cfg.ProxyUpstream != nil {
+		return nil, errors.New("RECORD_UPSTREAM and PROXY_UPSTREAM are mutually exclusive")
+	}
+
+	if cfg.RecordUpstream != nil && cfg.ReadOnly {
+		return nil, errors.New("RECORD_UPSTREAM and READ_ONLY are mutually exclusive")
+	}
+
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return nil, errors.New("TLS_CERT and TLS_KEY must be set together")
+	}
+
+	if cfg.TLSCert != "" && cfg.TLSSelfSigned {
+		return nil, errors.New("TLS_CERT and TLS_SELF_SIGNED are mutually exclusive")
+	}
+
+	if cfg.TLSClientCA != "" && cfg.TLSCert == "" && !cfg.TLSSelfSigned {
+		return nil, errors.New("TLS_CLIENT_CA requires TLS_CERT or TLS_SELF_SIGNED")
+	}
+
+	if cfg.TLSClientAuth != nil && cfg.TLSClientCA == "" {
+		return nil, errors.New("TLS_CLIENT_AUTH requires TLS_CLIENT_CA")
+	}
+
+	return &cfg, nil
 }
@@ -24,6 +24,25 @@ Environment Variables:
   RESPONSES_DIR - Directory where the response files are located (default: "./.sms_responses")
   EXTENSION_MIME_TYPE_MAP - File extension to http request Accept MIME type, e.g. "txt:text/plain"
   METHOD_STATUS_MAP - Request http method to response http status (default: "DELETE:202,GET:200,PATCH:204,POST:201,PUT:204")
+  SEQUENCE_END - What a sequence does after its last response, one of "repeat", "cycle" or "404" (default: "repeat")
+  ADMIN_PREFIX - Path prefix reserved for the admin API (default: "/__sms/")
+  JOURNAL_SIZE - How many received requests are kept in the journal, 0 disables it (default: 1000)
+  RECORD_UPSTREAM - Base URL requests not matching any route are forwarded to, saving the responses as new routes
+  PROXY_UPSTREAM - Base URL requests not matching any route are proxied to, without saving the responses
+  READ_ONLY - Respond not found to requests not matching any route instead of creating response files for them (default: false)
+  NOT_FOUND_FILE - File returned with a 404 status when no route matches, instead of a plain text message
+  SCRATCH_DIR - Directory where new response files are created instead of the responses one
+  NOT_ACCEPTABLE - Respond not acceptable to requests matching a route whose types they don't accept (default: false)
+  MIME_TYPE_ORDER - Precedence of the types of a route when the request accepts several of them, e.g. "application/json,application/xml"
+  LATENCY - Delay added to every response, a duration or a distribution as in file prefixes, e.g. "100ms-500ms"
+  THROTTLE - Bandwidth limit of every response body not setting its own, e.g. "64KBps" or "chunk-1KB-500ms"
+  TLS_CERT - Certificate file served over HTTPS, along with TLS_KEY
+  TLS_KEY - Private key file of TLS_CERT
+  TLS_SELF_SIGNED - Serve HTTPS with a certificate signed by a CA generated at startup (default: false)
+  TLS_HOSTS - Hosts the self-signed certificate is valid for besides localhost, e.g. "mock.internal,10.0.0.1"
+  TLS_CA_FILE - File the generated CA certificate is written to (default: "./sms-ca.pem")
+  TLS_CLIENT_CA - CA file client certificates are verified against
+  TLS_CLIENT_AUTH - How client certificates are handled, one of "request", "verify-if-given" or "require", requires TLS_CLIENT_CA (default: "request")
 
 `
 
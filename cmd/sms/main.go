@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"time"
@@ -30,13 +31,40 @@ func run() error {
 		return err
 	}
 
-	fs, err := filesystem.New(cfg.ResponsesDir, mime.New(cfg.Ext2MIMEType), cfg.Method2Status)
+	types := mime.New(cfg.Ext2MIMEType)
+
+	fs, err := filesystem.New(cfg.ResponsesDir, types, cfg.Method2Status, filesystem.WithScratch(cfg.ScratchDir))
 	if err != nil {
 		return err
 	}
 	defer fs.Stop()
 
-	s := server.New(cfg.Address, fs)
+	var notFound *filesystem.Descriptor
+	if cfg.NotFoundFile != "" {
+		if notFound, err = filesystem.File(cfg.NotFoundFile, http.StatusNotFound, types); err != nil {
+			return err
+		}
+	}
+
+	tlsCfg, err := tlsConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	s := server.New(cfg.Address, fs,
+		server.WithSequenceEnd(cfg.SequenceEnd),
+		server.WithAdminPrefix(cfg.AdminPrefix),
+		server.WithJournalSize(cfg.JournalSize),
+		server.WithRecordUpstream(cfg.RecordUpstream),
+		server.WithProxyUpstream(cfg.ProxyUpstream),
+		server.WithReadOnly(cfg.ReadOnly),
+		server.WithNotFound(notFound),
+		server.WithNotAcceptable(cfg.NotAcceptable),
+		server.WithTypeOrder(cfg.MIMETypeOrder...),
+		server.WithLatency(cfg.Latency),
+		server.WithThrottle(cfg.Throttle),
+		server.WithTLS(tlsCfg),
+	)
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
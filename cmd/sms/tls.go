@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/agukrapo/simpler-mock-server/internal/certs"
+	"github.com/rs/zerolog/log"
+)
+
+// clientAuth is how client certificates are handled, "request" doesn't fail the handshake on unverified ones
+// so requests can still be matched against them.
+type clientAuth tls.ClientAuthType
+
+func (ca *clientAuth) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "request":
+		*ca = clientAuth(tls.RequestClientCert)
+	case "verify-if-given":
+		*ca = clientAuth(tls.VerifyClientCertIfGiven)
+	case "require":
+		*ca = clientAuth(tls.RequireAndVerifyClientCert)
+	default:
+		return fmt.Errorf("invalid client auth %q", text)
+	}
+
+	return nil
+}
+
+// tlsConfig loads the TLS_CERT and TLS_KEY certificate, or generates a self-signed one writing its CA to TLS_CA_FILE,
+// nil if HTTPS isn't enabled.
+func tlsConfig(cfg *config) (*tls.Config, error) {
+	switch {
+	case cfg.TLSCert != "":
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("tls.LoadX509KeyPair: %w", err)
+		}
+
+		return withClientCA(cfg, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	case cfg.TLSSelfSigned:
+		cert, ca, err := certs.SelfSigned(cfg.TLSHosts...)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := os.WriteFile(filepath.Clean(cfg.TLSCAFile), ca, 0o644); err != nil { // #nosec G306 -- public certificate clients must read
+			return nil, fmt.Errorf("os.WriteFile: %w", err)
+		}
+		log.Info().Msgf("Self-signed CA certificate written to %s", cfg.TLSCAFile)
+
+		return withClientCA(cfg, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	default:
+		return nil, nil
+	}
+}
+
+// withClientCA asks for client certificates signed by the TLS_CLIENT_CA as TLS_CLIENT_AUTH says, if set.
+func withClientCA(cfg *config, out *tls.Config) (*tls.Config, error) {
+	if cfg.TLSClientCA == "" {
+		return out, nil
+	}
+
+	b, err := os.ReadFile(filepath.Clean(cfg.TLSClientCA))
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	out.ClientCAs = x509.NewCertPool()
+	if !out.ClientCAs.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", cfg.TLSClientCA)
+	}
+
+	out.ClientAuth = tls.RequestClientCert
+	if cfg.TLSClientAuth != nil {
+		out.ClientAuth = tls.ClientAuthType(*cfg.TLSClientAuth)
+	}
+
+	return out, nil
+}
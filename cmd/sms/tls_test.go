@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agukrapo/simpler-mock-server/internal/certs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientAuth_UnmarshalText(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    tls.ClientAuthType
+		wantErr string
+	}{
+		{"request", tls.RequestClientCert, ""},
+		{"verify-if-given", tls.VerifyClientCertIfGiven, ""},
+		{"require", tls.RequireAndVerifyClientCert, ""},
+		{"none", 0, `invalid client auth "none"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var got clientAuth
+			err := got.UnmarshalText([]byte(tt.text))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, tls.ClientAuthType(got))
+		})
+	}
+}
+
+func Test_withClientCA(t *testing.T) {
+	_, ca, err := certs.SelfSigned()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca, 0o600))
+	invalidFile := filepath.Join(dir, "invalid.pem")
+	require.NoError(t, os.WriteFile(invalidFile, []byte("invalid"), 0o600))
+
+	requireAuth := clientAuth(tls.RequireAndVerifyClientCert)
+
+	tests := []struct {
+		name     string
+		cfg      config
+		wantAuth tls.ClientAuthType
+		wantCAs  bool
+		wantErr  string
+	}{
+		{"no client CA", config{}, tls.NoClientCert, false, ""},
+		{"default auth", config{TLSClientCA: caFile}, tls.RequestClientCert, true, ""},
+		{"require", config{TLSClientCA: caFile, TLSClientAuth: &requireAuth}, tls.RequireAndVerifyClientCert, true, ""},
+		{"missing file", config{TLSClientCA: filepath.Join(dir, "missing.pem")}, 0, false, "os.ReadFile: open " + filepath.Join(dir, "missing.pem") + ": no such file or directory"},
+		{"no certificates", config{TLSClientCA: invalidFile}, 0, false, "no certificates found in " + invalidFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := withClientCA(&tt.cfg, &tls.Config{MinVersion: tls.VersionTLS12})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantAuth, got.ClientAuth)
+			assert.Equal(t, tt.wantCAs, got.ClientCAs != nil)
+		})
+	}
+}
+
+func Test_parseConfig_tls(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"client auth without client CA", map[string]string{"TLS_SELF_SIGNED": "true", "TLS_CLIENT_AUTH": "require"}, "TLS_CLIENT_AUTH requires TLS_CLIENT_CA"},
+		{"client CA without TLS", map[string]string{"TLS_CLIENT_CA": "ca.pem"}, "TLS_CLIENT_CA requires TLS_CERT or TLS_SELF_SIGNED"},
+		{"client CA and auth", map[string]string{"TLS_SELF_SIGNED": "true", "TLS_CLIENT_CA": "ca.pem", "TLS_CLIENT_AUTH": "require"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := parseConfig()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -65,6 +65,23 @@ func Test(t *testing.T) {
 		assert.Equal(t, "simpler-mock-server UP", string(b))
 	})
 
+	t.Run("GET path parameter", func(t *testing.T) {
+		url := fmt.Sprintf("http://%s/api/people/a3b69b44-d562-11eb-b8bc-0242ac130003", endpoint)
+		req, err := requests.New(url).Build(t.Context())
+		require.NoError(t, err)
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		assert.JSONEq(t, "{\n  \"name\": \"John Doe\"\n}", string(b))
+	})
+
 	t.Run("PATCH", func(t *testing.T) {
 		url := fmt.Sprintf("http://%s/api/people/a3b69b44-d562-11eb-b8bc-0242ac130003", endpoint)
 		req, err := requests.New(url).Method(http.MethodPatch).Build(t.Context())
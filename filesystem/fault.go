@@ -0,0 +1,61 @@
+package filesystem
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FaultKind is a network failure simulated instead of sending a well-formed response.
+type FaultKind string
+
+const (
+	// FaultReset resets the connection without responding.
+	FaultReset FaultKind = "reset"
+	// FaultEmpty closes the connection without responding.
+	FaultEmpty FaultKind = "empty"
+	// FaultGarbage responds random bytes instead of an HTTP response.
+	FaultGarbage FaultKind = "garbage"
+	// FaultCloseAfterHeaders closes the connection right after sending the response headers.
+	FaultCloseAfterHeaders FaultKind = "close-after-headers"
+	// FaultTruncate closes the connection after sending a percentage of the response body.
+	FaultTruncate FaultKind = "truncate"
+)
+
+type Fault struct {
+	Kind FaultKind
+	// Percent is the part of the body sent by FaultTruncate.
+	Percent int
+}
+
+func (f Fault) String() string {
+	if f.Kind == FaultTruncate {
+		return string(f.Kind) + "-" + strconv.Itoa(f.Percent) + "%"
+	}
+
+	return string(f.Kind)
+}
+
+// parseFault reads a fault prefix part, like `reset` or `truncate-50%`.
+func parseFault(part string) (*Fault, bool) {
+	switch kind := FaultKind(part); kind {
+	case FaultReset, FaultEmpty, FaultGarbage, FaultCloseAfterHeaders:
+		return &Fault{Kind: kind}, true
+	}
+
+	percent, ok := strings.CutPrefix(part, string(FaultTruncate)+"-")
+	if !ok {
+		return nil, false
+	}
+
+	percent, ok = strings.CutSuffix(percent, "%")
+	if !ok {
+		return nil, false
+	}
+
+	n, err := strconv.Atoi(percent)
+	if err != nil || n < 0 || n >= 100 {
+		return nil, false
+	}
+
+	return &Fault{Kind: FaultTruncate, Percent: n}, true
+}
@@ -0,0 +1,36 @@
+package filesystem
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseFault(t *testing.T) {
+	tests := []struct {
+		part   string
+		want   *Fault
+		wantOk bool
+	}{
+		{"reset", &Fault{Kind: FaultReset}, true},
+		{"empty", &Fault{Kind: FaultEmpty}, true},
+		{"garbage", &Fault{Kind: FaultGarbage}, true},
+		{"close-after-headers", &Fault{Kind: FaultCloseAfterHeaders}, true},
+		{"truncate-0%", &Fault{Kind: FaultTruncate}, true},
+		{"truncate-50%", &Fault{Kind: FaultTruncate, Percent: 50}, true},
+		{"truncate-100%", nil, false},
+		{"truncate-50", nil, false},
+		{"truncate", nil, false},
+		{"1s", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.part, func(t *testing.T) {
+			got, ok := parseFault(tt.part)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+			if ok {
+				assert.Equal(t, tt.part, got.String())
+			}
+		})
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -14,23 +17,87 @@ import (
 	"time"
 
 	"github.com/agukrapo/simpler-mock-server/internal/headers"
+	"github.com/agukrapo/simpler-mock-server/internal/latency"
 	"github.com/agukrapo/simpler-mock-server/internal/mime"
+	"github.com/agukrapo/simpler-mock-server/internal/throttle"
 	"github.com/fsnotify/fsnotify"
 	"github.com/rs/zerolog/log"
 )
 
+// SequenceEnd tells what a sequence of responses does once all of them were returned.
+type SequenceEnd string
+
+const (
+	RepeatLast SequenceEnd = "repeat"
+	Cycle      SequenceEnd = "cycle"
+	NotFound   SequenceEnd = "404"
+)
+
+func (se *SequenceEnd) UnmarshalText(text []byte) error {
+	switch v := SequenceEnd(text); v {
+	case RepeatLast, Cycle, NotFound:
+		*se = v
+		return nil
+	default:
+		return fmt.Errorf("invalid sequence end %q", text)
+	}
+}
+
 type Descriptor struct {
 	Method string
 	Path   string
 	Route  string
 	Status int
 	Type   mime.Type
-	Delay  time.Duration
+	Delay  latency.Distribution
+	Query  url.Values
+	Header http.Header
 	Reader func() (io.ReadCloser, error)
+
+	// Template is true for `.tmpl` files, whose body and header values are rendered with text/template.
+	Template bool
+
+	// Fault, if set, breaks the response instead of sending it.
+	Fault *Fault
+
+	// Throttle, if set, limits the bandwidth the response body is sent at.
+	Throttle *throttle.Rate
+
+	// Loop is true for files with a `loop` prefix, whose events are streamed over and over until the client disconnects.
+	Loop bool
+
+	// Stream is true for files with a `stream` or `stream-{interval}` prefix, whose lines are sent one at a time,
+	// StreamInterval apart.
+	Stream         bool
+	StreamInterval time.Duration
+
+	// Default is true for files with a `default` prefix, preferred among the types of their route when the request accepts several of them.
+	Default bool
+
+	// Sequence is the position of the descriptor among the ones of the same route and criteria, 0 if it is not part of a sequence.
+	Sequence    int
+	SequenceEnd SequenceEnd
+
+	Scenario *Scenario
+
+	// Fallback is true for files in `_default` dirs, returned with their status when resolving requests under Route fails.
+	// An empty Method means any of them.
+	Fallback bool
+
+	// MatchHeaders maps header names to their expected value, "*" matches any value and nil an absent header.
+	MatchHeaders map[string]*string
+	MatchBody    *BodyMatcher
+	MatchClient  *ClientMatcher
 }
 
+const (
+	templateExtension = "tmpl"
+	fallbackDir       = "_default"
+)
+
 type FS struct {
-	root string
+	root    string
+	scratch string
 
 	watcher *fsnotify.Watcher
 	paths   map[string]chan fsnotify.Event
@@ -42,7 +109,16 @@ type FS struct {
 	method2Status map[string]int
 }
 
-func New(root string, types *mime.Types, method2Status map[string]int) (*FS, error) {
+type Option func(*FS)
+
+// WithScratch makes new response files to be created in the scratch dir instead of the root one.
+func WithScratch(dir string) Option {
+	return func(fs *FS) {
+		fs.scratch = dir
+	}
+}
+
+func New(root string, types *mime.Types, method2Status map[string]int, opts ...Option) (*FS, error) {
 	if err := validate(root); err != nil {
 		return nil, err
 	}
@@ -57,13 +133,29 @@ func New(root string, types *mime.Types, method2Status map[string]int) (*FS, err
 		return nil, err
 	}
 
-	return &FS{
+	out := &FS{
 		root:          root,
 		watcher:       watcher,
 		events:        make(chan struct{}),
 		types:         types,
 		method2Status: method2Status,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(out)
+	}
+
+	if out.scratch != "" {
+		if err := os.MkdirAll(out.scratch, 0o750); err != nil {
+			return nil, fmt.Errorf("os.MkdirAll: %w", err)
+		}
+
+		if out.scratch, err = filepath.Abs(out.scratch); err != nil {
+			return nil, fmt.Errorf("filepath.Abs: %w", err)
+		}
+	}
+
+	return out, nil
 }
 
 func (fs *FS) Stop() {
@@ -83,21 +175,31 @@ func (fs *FS) Paths() ([]*Descriptor, error) {
 
 	fs.resetWatcher()
 
-	if err := fs.watcher.Add(fs.root); err != nil {
-		log.Error().Err(err).Msgf("Failed to watch %s dir", fs.root)
-	}
-
 	var out []*Descriptor
 
-	for method, status := range fs.method2Status {
-		sp, err := fs.subPaths(method, status)
-		if err != nil {
-			log.Error().Str("method", method).Int("status", status).Err(err).Msg("Failed to process paths")
+	for _, root := range fs.roots() {
+		if err := fs.watcher.Add(root); err != nil {
+			log.Error().Err(err).Msgf("Failed to watch %s dir", root)
+		}
 
-			continue
+		if dir := filepath.Join(root, fallbackDir); validate(dir) == nil {
+			if err := fs.watcher.Add(dir); err != nil {
+				log.Error().Err(err).Msgf("Failed to watch %s dir", dir)
+			}
+
+			out = append(out, fs.fallbacks(root, dir, "", "/")...)
 		}
 
-		out = append(out, sp...)
+		for method, status := range fs.methods() {
+			sp, err := fs.subPaths(root, method, status)
+			if err != nil {
+				log.Error().Str("method", method).Int("status", status).Err(err).Msg("Failed to process paths")
+
+				continue
+			}
+
+			out = append(out, sp...)
+		}
 	}
 
 	go fs.eventLoop()
@@ -105,8 +207,27 @@ func (fs *FS) Paths() ([]*Descriptor, error) {
 	return out, nil
 }
 
-func (fs *FS) subPaths(method string, status int) ([]*Descriptor, error) {
-	root := filepath.Clean(filepath.Join(fs.root, method))
+// methods returns the status of every method dir, including the WebSocket one.
+func (fs *FS) methods() map[string]int {
+	out := maps.Clone(fs.method2Status)
+	if out == nil {
+		out = make(map[string]int)
+	}
+	out[WebSocket] = http.StatusSwitchingProtocols
+
+	return out
+}
+
+func (fs *FS) roots() []string {
+	if fs.scratch == "" {
+		return []string{fs.root}
+	}
+
+	return []string{fs.root, fs.scratch}
+}
+
+func (fs *FS) subPaths(parent, method string, status int) ([]*Descriptor, error) {
+	root := filepath.Clean(filepath.Join(parent, method))
 
 	if _, err := os.Open(root); os.IsNotExist(err) {
 		return nil, nil
@@ -123,14 +244,20 @@ func (fs *FS) subPaths(method string, status int) ([]*Descriptor, error) {
 			if err := fs.watcher.Add(path); err != nil {
 				log.Error().Err(err).Msgf("Failed to watch %s dir", path)
 			}
+
+			if info.Name() == fallbackDir {
+				route := strings.TrimPrefix(filepath.Dir(path), root) + "/"
+				out = append(out, fs.fallbacks(parent, path, method, route)...)
+				return filepath.SkipDir
+			}
 		}
 
-		if !info.Mode().IsRegular() {
+		if !info.Mode().IsRegular() || isMeta(path) {
 			return nil
 		}
 
 		dir, base := filepath.Split(path)
-		dir = strings.TrimPrefix(dir, filepath.Join(fs.root, method))
+		dir = strings.TrimPrefix(dir, root)
 
 		filename, ext, err := splitBase(base)
 		if err != nil {
@@ -138,22 +265,51 @@ func (fs *FS) subPaths(method string, status int) ([]*Descriptor, error) {
 			return nil
 		}
 
-		name, status, delay, err := parsePrefix(filename, status)
+		template := ext == templateExtension
+		if template {
+			if filename, ext, err = splitBase(filename); err != nil {
+				log.Error().Err(err).Msgf("Failed to split path %s", base)
+				return nil
+			}
+		}
+
+		name, pre, err := parsePrefix(filename, status)
 		if err != nil {
 			log.Error().Err(err).Msgf("Failed to parse prefix from filename %s", filename)
 			return nil
 		}
 
+		m, err := readMeta(path)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to read metadata of %s", base)
+			return nil
+		}
+
 		out = append(out, &Descriptor{
 			Method: method,
-			Path:   strings.TrimPrefix(path, filepath.Dir(fs.root)+"/"),
+			Path:   strings.TrimPrefix(path, filepath.Dir(parent)+"/"),
 			Route:  dir + name,
-			Status: status,
+			Status: pre.status,
 			Type:   fs.types.Type(mime.Extension(ext)),
-			Delay:  delay,
+			Delay:  pre.delay,
+			Query:  pre.query,
+			Header: m.header(),
 			Reader: func() (io.ReadCloser, error) {
 				return os.Open(filepath.Clean(path))
 			},
+			Template:       template,
+			Fault:          pre.fault,
+			Throttle:       pre.throttle,
+			Default:        pre.isDefault,
+			Loop:           pre.loop,
+			Stream:         pre.stream,
+			StreamInterval: pre.streamInterval,
+			Sequence:       pre.sequence,
+			SequenceEnd:    m.SequenceEnd,
+			Scenario:       m.Scenario,
+			MatchHeaders:   m.Match.Headers,
+			MatchBody:      m.Match.Body,
+			MatchClient:    m.Match.Client,
 		})
 
 		return nil
@@ -165,6 +321,56 @@ func (fs *FS) subPaths(method string, status int) ([]*Descriptor, error) {
 	return out, nil
 }
 
+// fallbacks describes the `{status}.{ext}` files of a fallback dir, returned when resolving requests for the route fails.
+func (fs *FS) fallbacks(parent, dir, method, route string) []*Descriptor {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to read %s dir", dir)
+		return nil
+	}
+
+	var out []*Descriptor
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.Type().IsRegular() || isMeta(path) {
+			continue
+		}
+
+		filename, ext, err := splitBase(entry.Name())
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to split path %s", entry.Name())
+			continue
+		}
+
+		status, err := strconv.Atoi(filename)
+		if err != nil {
+			log.Error().Err(err).Msgf("Invalid fallback status %s", filename)
+			continue
+		}
+
+		m, err := readMeta(path)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to read metadata of %s", entry.Name())
+			continue
+		}
+
+		out = append(out, &Descriptor{
+			Method: method,
+			Path:   strings.TrimPrefix(path, filepath.Dir(parent)+"/"),
+			Route:  route,
+			Status: status,
+			Type:   fs.types.Type(mime.Extension(ext)),
+			Header: m.header(),
+			Reader: func() (io.ReadCloser, error) {
+				return os.Open(filepath.Clean(path))
+			},
+			Fallback: true,
+		})
+	}
+
+	return out
+}
+
 func (fs *FS) eventLoop() {
 	t := time.AfterFunc(math.MaxInt64, func() {
 		select {
@@ -180,7 +386,7 @@ func (fs *FS) eventLoop() {
 			if !ok {
 				return
 			}
-			if event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) || event.Has(fsnotify.Write) && isMeta(event.Name) {
 				t.Reset(200 * time.Millisecond)
 			}
 		case err, ok := <-fs.watcher.Errors:
@@ -194,27 +400,66 @@ func (fs *FS) eventLoop() {
 }
 
 func (fs *FS) Create(req *http.Request) (*Descriptor, error) {
-	ext := fs.types.Extension(headers.Accept(req))
-	path := strings.TrimSuffix(req.URL.Path, "/")
-	file := filepath.Clean(filepath.Join(fs.root, req.Method, fmt.Sprintf("%s.%s", path, ext)))
+	return fs.create(req, fs.method2Status[req.Method], headers.Accept(req), "", nil)
+}
+
+// Record persists a response received from an upstream server, prefixing the file with its status and the request
+// query, so requests differing in their query get their own response.
+func (fs *FS) Record(req *http.Request, status int, typ mime.Type, body []byte) (*Descriptor, error) {
+	prefix := strconv.Itoa(status)
+	query := req.URL.Query()
+	if len(query) != 0 {
+		// dots separate the prefix parts.
+		prefix += "." + strings.ReplaceAll(query.Encode(), ".", "%2E")
+	}
+
+	desc, err := fs.create(req, status, typ, prefix+"___", body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(query) != 0 {
+		desc.Query = query
+	}
+
+	return desc, nil
+}
+
+func (fs *FS) create(req *http.Request, status int, typ mime.Type, prefix string, body []byte) (*Descriptor, error) {
+	ext := fs.types.Extension(typ)
+	route := strings.TrimSuffix(req.URL.Path, "/")
+	dir, name := path.Split(route)
+	base := fs.root
+	if fs.scratch != "" {
+		base = fs.scratch
+	}
+
+	file := filepath.Clean(filepath.Join(base, req.Method, dir, fmt.Sprintf("%s%s.%s", prefix, name, ext)))
 
 	if err := os.MkdirAll(filepath.Dir(file), 0o750); err != nil {
 		return nil, fmt.Errorf("os.MkdirAll: %w", err)
 	}
 
-	if _, err := os.Create(file); err != nil {
-		return nil, fmt.Errorf("os.Create: %w", err)
+	// existing files are never overwritten, they may be routes whose criteria the request didn't match.
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("os.OpenFile: %w", err)
 	}
+	defer f.Close()
 
-	if path == "" {
-		path = "/"
+	if _, err := f.Write(body); err != nil {
+		return nil, fmt.Errorf("f.Write: %w", err)
+	}
+
+	if route == "" {
+		route = "/"
 	}
 
 	return &Descriptor{
 		Method: req.Method,
-		Path:   strings.TrimPrefix(file, filepath.Dir(fs.root)+"/"),
-		Route:  path,
-		Status: fs.method2Status[req.Method],
+		Path:   strings.TrimPrefix(file, filepath.Dir(base)+"/"),
+		Route:  route,
+		Status: status,
 		Type:   fs.types.Type(ext),
 		Reader: func() (io.ReadCloser, error) {
 			return os.Open(file)
@@ -222,6 +467,34 @@ func (fs *FS) Create(req *http.Request) (*Descriptor, error) {
 	}, nil
 }
 
+// File describes a response file outside the responses dir, like the not found one.
+func File(path string, status int, types *mime.Types) (*Descriptor, error) {
+	path = filepath.Clean(path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Stat: %w", err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a file", path)
+	}
+
+	_, ext, err := splitBase(filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Descriptor{
+		Path:   path,
+		Status: status,
+		Type:   types.Type(mime.Extension(ext)),
+		Reader: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+	}, nil
+}
+
 func validate(dir string) error {
 	f, err := os.Open(filepath.Clean(dir))
 	if err != nil {
@@ -249,40 +522,127 @@ func splitBase(path string) (string, string, error) {
 	return strings.TrimSuffix(path, ext), strings.TrimPrefix(ext, "."), nil
 }
 
-func parsePrefix(name string, status int) (string, int, time.Duration, error) {
+type prefix struct {
+	status    int
+	sequence  int
+	delay     latency.Distribution
+	query     url.Values
+	isDefault bool
+	loop      bool
+
+	stream         bool
+	streamInterval time.Duration
+	fault          *Fault
+	throttle       *throttle.Rate
+}
+
+// parseStream reads a stream prefix part, like `stream` or `stream-100ms`.
+func parseStream(part string) (time.Duration, bool) {
+	if part == "stream" {
+		return 0, true
+	}
+
+	interval, ok := strings.CutPrefix(part, "stream-")
+	if !ok {
+		return 0, false
+	}
+
+	d, err := time.ParseDuration(interval)
+	if err != nil || d < 0 {
+		return 0, false
+	}
+
+	return d, true
+}
+
+func parsePrefix(name string, status int) (string, prefix, error) {
+	out := prefix{status: status}
+
 	chunks := strings.Split(name, "___")
 	if len(chunks) != 2 {
-		return name, status, 0, nil
+		return name, out, nil
 	}
 
 	name = chunks[1]
 
-	var (
-		delay time.Duration
-		ok    bool
-	)
+	var ok bool
 
 	for _, part := range strings.Split(chunks[0], ".") {
 		ns, err := strconv.Atoi(part)
+		if err == nil && ns > 0 && ns < 100 {
+			out.sequence = ns
+			ok = true
+			continue
+		}
 		if err == nil {
-			status = ns
+			out.status = ns
+			ok = true
+			continue
+		}
+
+		if fault, found := parseFault(part); found {
+			out.fault = fault
+			ok = true
+			continue
+		}
+
+		if rate, err := throttle.Parse(part); err == nil {
+			out.throttle = rate
 			ok = true
 			continue
 		}
 
-		duration, err := time.ParseDuration(part)
+		delay, err := latency.Parse(part)
 		if err == nil {
-			delay = duration
+			out.delay = delay
+			ok = true
+			continue
+		}
+
+		if part == "default" {
+			out.isDefault = true
 			ok = true
 			continue
 		}
+
+		if part == "loop" {
+			out.loop = true
+			ok = true
+			continue
+		}
+
+		if interval, found := parseStream(part); found {
+			out.stream, out.streamInterval = true, interval
+			ok = true
+			continue
+		}
+
+		if strings.HasPrefix(part, "@") {
+			ok = true
+			continue
+		}
+
+		if strings.Contains(part, "=") {
+			query, err := url.ParseQuery(part)
+			if err == nil {
+				// query parts add up, like `page=2.size=10`.
+				if out.query == nil {
+					out.query = make(url.Values)
+				}
+				for k, values := range query {
+					out.query[k] = append(out.query[k], values...)
+				}
+				ok = true
+				continue
+			}
+		}
 	}
 
 	if !ok {
-		return name, status, delay, fmt.Errorf("invalid prefix %s", chunks[0])
+		return name, out, fmt.Errorf("invalid prefix %s", chunks[0])
 	}
 
-	return name, status, delay, nil
+	return name, out, nil
 }
 
 func (fs *FS) Notify() <-chan struct{} {
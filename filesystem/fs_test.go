@@ -4,10 +4,17 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/agukrapo/simpler-mock-server/internal/latency"
+	"github.com/agukrapo/simpler-mock-server/internal/mime"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_splitBase(t *testing.T) {
@@ -42,7 +49,14 @@ func Test_parsePrefix(t *testing.T) {
 		args       args
 		wantName   string
 		wantStatus int
-		wantDelay  time.Duration
+		wantDelay  latency.Distribution
+		wantQuery  url.Values
+		wantSeq    int
+		wantDef    bool
+		wantLoop   bool
+		wantStream time.Duration
+		wantFault  *Fault
+		wantRate   string
 		wantErr    assert.ErrorAssertionFunc
 	}{
 		{
@@ -50,7 +64,6 @@ func Test_parsePrefix(t *testing.T) {
 			args:       args{name: "invoice.pdf", status: http.StatusOK},
 			wantName:   "invoice.pdf",
 			wantStatus: http.StatusOK,
-			wantDelay:  0,
 			wantErr:    assert.NoError,
 		},
 		{
@@ -58,7 +71,6 @@ func Test_parsePrefix(t *testing.T) {
 			args:       args{name: "500___invoice.pdf", status: http.StatusOK},
 			wantName:   "invoice.pdf",
 			wantStatus: http.StatusInternalServerError,
-			wantDelay:  0,
 			wantErr:    assert.NoError,
 		},
 		{
@@ -66,7 +78,7 @@ func Test_parsePrefix(t *testing.T) {
 			args:       args{name: "9m___invoice.pdf", status: http.StatusCreated},
 			wantName:   "invoice.pdf",
 			wantStatus: http.StatusCreated,
-			wantDelay:  9 * time.Minute,
+			wantDelay:  latency.Fixed(9 * time.Minute),
 			wantErr:    assert.NoError,
 		},
 		{
@@ -74,7 +86,7 @@ func Test_parsePrefix(t *testing.T) {
 			args:       args{name: "202.3s___invoice.pdf", status: http.StatusBadGateway},
 			wantName:   "invoice.pdf",
 			wantStatus: http.StatusAccepted,
-			wantDelay:  3 * time.Second,
+			wantDelay:  latency.Fixed(3 * time.Second),
 			wantErr:    assert.NoError,
 		},
 		{
@@ -82,7 +94,96 @@ func Test_parsePrefix(t *testing.T) {
 			args:       args{name: "123h.400___invoice.pdf", status: http.StatusBadGateway},
 			wantName:   "invoice.pdf",
 			wantStatus: http.StatusBadRequest,
-			wantDelay:  123 * time.Hour,
+			wantDelay:  latency.Fixed(123 * time.Hour),
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "uniform delay prefix",
+			args:       args{name: "500.100ms-500ms___invoice.pdf", status: http.StatusOK},
+			wantName:   "invoice.pdf",
+			wantStatus: http.StatusInternalServerError,
+			wantDelay:  latency.Uniform{Min: 100 * time.Millisecond, Max: 500 * time.Millisecond},
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "query prefix",
+			args:       args{name: "page=2&size=10___invoice.pdf", status: http.StatusOK},
+			wantName:   "invoice.pdf",
+			wantStatus: http.StatusOK,
+			wantQuery:  url.Values{"page": {"2"}, "size": {"10"}},
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "several query prefixes",
+			args:       args{name: "page=2.200.size=10___invoice.pdf", status: http.StatusOK},
+			wantName:   "invoice.pdf",
+			wantStatus: http.StatusOK,
+			wantQuery:  url.Values{"page": {"2"}, "size": {"10"}},
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "status, delay and query prefix",
+			args:       args{name: "404.1s.q=foo%2Ebar___invoice.pdf", status: http.StatusOK},
+			wantName:   "invoice.pdf",
+			wantStatus: http.StatusNotFound,
+			wantDelay:  latency.Fixed(time.Second),
+			wantQuery:  url.Values{"q": {"foo.bar"}},
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "sequence prefix",
+			args:       args{name: "2.202___invoice.pdf", status: http.StatusOK},
+			wantName:   "invoice.pdf",
+			wantStatus: http.StatusAccepted,
+			wantSeq:    2,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "default prefix",
+			args:       args{name: "default.202___invoice.pdf", status: http.StatusOK},
+			wantName:   "invoice.pdf",
+			wantStatus: http.StatusAccepted,
+			wantDef:    true,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "fault prefix",
+			args:       args{name: "200.truncate-50%___invoice.pdf", status: http.StatusOK},
+			wantName:   "invoice.pdf",
+			wantStatus: http.StatusOK,
+			wantFault:  &Fault{Kind: FaultTruncate, Percent: 50},
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "throttle prefix",
+			args:       args{name: "64KBps.1s___invoice.pdf", status: http.StatusOK},
+			wantName:   "invoice.pdf",
+			wantStatus: http.StatusOK,
+			wantDelay:  latency.Fixed(time.Second),
+			wantRate:   "64KBps",
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "loop prefix",
+			args:       args{name: "loop___events.sse", status: http.StatusOK},
+			wantName:   "events.sse",
+			wantStatus: http.StatusOK,
+			wantLoop:   true,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "stream prefix",
+			args:       args{name: "stream-100ms___logs.ndjson", status: http.StatusOK},
+			wantName:   "logs.ndjson",
+			wantStatus: http.StatusOK,
+			wantStream: 100 * time.Millisecond,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "label prefix",
+			args:       args{name: "@acme___invoice.pdf", status: http.StatusOK},
+			wantName:   "invoice.pdf",
+			wantStatus: http.StatusOK,
 			wantErr:    assert.NoError,
 		},
 		{
@@ -90,19 +191,29 @@ func Test_parsePrefix(t *testing.T) {
 			args:       args{name: "nonsense___invoice.pdf", status: http.StatusContinue},
 			wantName:   "invoice.pdf",
 			wantStatus: http.StatusContinue,
-			wantDelay:  0,
 			wantErr:    err(`invalid prefix nonsense`),
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, got1, got2, err := parsePrefix(tt.args.name, tt.args.status)
+			got, got1, err := parsePrefix(tt.args.name, tt.args.status)
 			if !tt.wantErr(t, err, fmt.Sprintf("parsePrefix(%v, %v)", tt.args.name, tt.args.status)) {
 				return
 			}
 			assert.Equalf(t, tt.wantName, got, "parsePrefix(%v, %v)", tt.args.name, tt.args.status)
-			assert.Equalf(t, tt.wantStatus, got1, "parsePrefix(%v, %v)", tt.args.name, tt.args.status)
-			assert.Equalf(t, tt.wantDelay, got2, "parsePrefix(%v, %v)", tt.args.name, tt.args.status)
+			assert.Equalf(t, tt.wantStatus, got1.status, "parsePrefix(%v, %v)", tt.args.name, tt.args.status)
+			assert.Equalf(t, tt.wantDelay, got1.delay, "parsePrefix(%v, %v)", tt.args.name, tt.args.status)
+			assert.Equalf(t, tt.wantQuery, got1.query, "parsePrefix(%v, %v)", tt.args.name, tt.args.status)
+			assert.Equalf(t, tt.wantSeq, got1.sequence, "parsePrefix(%v, %v)", tt.args.name, tt.args.status)
+			assert.Equalf(t, tt.wantDef, got1.isDefault, "parsePrefix(%v, %v)", tt.args.name, tt.args.status)
+			assert.Equalf(t, tt.wantLoop, got1.loop, "parsePrefix(%v, %v)", tt.args.name, tt.args.status)
+			assert.Equalf(t, tt.wantStream, got1.streamInterval, "parsePrefix(%v, %v)", tt.args.name, tt.args.status)
+			assert.Equalf(t, tt.wantFault, got1.fault, "parsePrefix(%v, %v)", tt.args.name, tt.args.status)
+			if tt.wantRate != "" {
+				assert.Equalf(t, tt.wantRate, got1.throttle.String(), "parsePrefix(%v, %v)", tt.args.name, tt.args.status)
+			} else {
+				assert.Nilf(t, got1.throttle, "parsePrefix(%v, %v)", tt.args.name, tt.args.status)
+			}
 		})
 	}
 }
@@ -112,3 +223,135 @@ func err(msg string) assert.ErrorAssertionFunc {
 		return assert.EqualError(t, err, msg)
 	}
 }
+
+func TestFS_Record(t *testing.T) {
+	root := t.TempDir()
+	fs, err := New(root, mime.New(nil), map[string]int{http.MethodGet: http.StatusOK})
+	require.NoError(t, err)
+	defer fs.Stop()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/people/", nil)
+	desc, err := fs.Record(req, http.StatusNotFound, "text/csv", []byte("a,b"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "/api/people", desc.Route)
+	assert.Equal(t, http.StatusNotFound, desc.Status)
+	assert.Equal(t, mime.Type("text/csv"), desc.Type)
+
+	b, err := os.ReadFile(filepath.Join(root, "GET", "api", "404___people.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, "a,b", string(b))
+
+	descs, err := fs.Paths()
+	require.NoError(t, err)
+	require.Len(t, descs, 1)
+	assert.Equal(t, "/api/people", descs[0].Route)
+	assert.Equal(t, http.StatusNotFound, descs[0].Status)
+}
+
+func TestFS_Record_query(t *testing.T) {
+	root := t.TempDir()
+	fs, err := New(root, mime.New(nil), map[string]int{http.MethodGet: http.StatusOK})
+	require.NoError(t, err)
+	defer fs.Stop()
+
+	for _, q := range []string{"q=foo&version=1.2", "q=bar"} {
+		_, err := fs.Record(httptest.NewRequest(http.MethodGet, "/search?"+q, nil), http.StatusOK, "application/json", []byte(q))
+		require.NoError(t, err)
+	}
+
+	_, err = os.Stat(filepath.Join(root, "GET", "200.q=foo&version=1%2E2___search.json"))
+	require.NoError(t, err)
+
+	descs, err := fs.Paths()
+	require.NoError(t, err)
+	require.Len(t, descs, 2)
+
+	queries := []url.Values{descs[0].Query, descs[1].Query}
+	assert.ElementsMatch(t, []url.Values{{"q": {"bar"}}, {"q": {"foo"}, "version": {"1.2"}}}, queries)
+}
+
+func TestFS_Create_existing(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "GET", "api"), 0o750))
+	file := filepath.Join(root, "GET", "api", "partners.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"name":"acme"}`), 0o600))
+
+	fs, err := New(root, mime.New(nil), map[string]int{http.MethodGet: http.StatusOK})
+	require.NoError(t, err)
+	defer fs.Stop()
+
+	_, err = fs.Create(httptest.NewRequest(http.MethodGet, "/api/partners", nil))
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"acme"}`, string(b))
+}
+
+func TestFS_Create_scratch(t *testing.T) {
+	root, scratch := t.TempDir(), filepath.Join(t.TempDir(), "scratch")
+	fs, err := New(root, mime.New(nil), map[string]int{http.MethodGet: http.StatusOK}, WithScratch(scratch))
+	require.NoError(t, err)
+	defer fs.Stop()
+
+	req := httptest.NewRequest(http.MethodGet, "/wp-admin", nil)
+	_, err = fs.Create(req)
+	require.NoError(t, err)
+
+	assert.FileExists(t, filepath.Join(scratch, "GET", "wp-admin.json"))
+	assert.NoDirExists(t, filepath.Join(root, "GET"))
+
+	descs, err := fs.Paths()
+	require.NoError(t, err)
+	require.Len(t, descs, 1)
+	assert.Equal(t, "/wp-admin", descs[0].Route)
+	assert.Equal(t, "scratch/GET/wp-admin.json", descs[0].Path)
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "404.xml")
+	require.NoError(t, os.WriteFile(path, []byte("<error/>"), 0o600))
+
+	desc, err := File(path, http.StatusNotFound, mime.New(nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, desc.Status)
+	assert.Equal(t, mime.Type("application/xml"), desc.Type)
+
+	_, err = File(filepath.Dir(path), http.StatusNotFound, mime.New(nil))
+	assert.ErrorContains(t, err, "is not a file")
+}
+
+func TestFS_Paths_fallbacks(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{
+		"_default/404.json",
+		"GET/_default/404.xml",
+		"GET/api/_default/500.json",
+		"GET/api/_default/nonsense.json",
+		"GET/api/people.json",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, filepath.Dir(p)), 0o750))
+		require.NoError(t, os.WriteFile(filepath.Join(root, p), nil, 0o600))
+	}
+
+	fs, err := New(root, mime.New(nil), map[string]int{http.MethodGet: http.StatusOK})
+	require.NoError(t, err)
+	defer fs.Stop()
+
+	descs, err := fs.Paths()
+	require.NoError(t, err)
+
+	got := make(map[string]string)
+	for _, desc := range descs {
+		got[desc.Path] = fmt.Sprintf("%s %s %d %s %t", desc.Method, desc.Route, desc.Status, desc.Type, desc.Fallback)
+	}
+
+	base := filepath.Base(root)
+	assert.Equal(t, map[string]string{
+		base + "/_default/404.json":         " / 404 application/json true",
+		base + "/GET/_default/404.xml":      "GET / 404 application/xml true",
+		base + "/GET/api/_default/500.json": "GET /api/ 500 application/json true",
+		base + "/GET/api/people.json":       "GET /api/people 200 application/json false",
+	}, got)
+}
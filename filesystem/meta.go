@@ -0,0 +1,151 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const metaSuffix = ".meta.json"
+
+type meta struct {
+	Match struct {
+		Headers map[string]*string `json:"headers"`
+		Body    *BodyMatcher       `json:"body"`
+		Client  *ClientMatcher     `json:"client"`
+	} `json:"match"`
+	Headers     map[string]headerValues `json:"headers"`
+	SequenceEnd SequenceEnd             `json:"sequenceEnd"`
+	Scenario    *Scenario               `json:"scenario"`
+}
+
+// Scenario binds a response to the state of a named scenario shared across routes, all of them start in the "Started" state.
+type Scenario struct {
+	Name string `json:"name"`
+	// Requires is the state the scenario must be in for the response to be returned, any state if empty.
+	Requires string `json:"requires"`
+	// Next is the state the scenario moves to once the response is returned, unchanged if empty.
+	Next string `json:"next"`
+}
+
+// headerValues unmarshals either a single string or an array of them.
+type headerValues []string
+
+func (hv *headerValues) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*hv = headerValues{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return fmt.Errorf("header values must be a string or an array of strings: %w", err)
+	}
+
+	*hv = multiple
+	return nil
+}
+
+func (m *meta) header() http.Header {
+	if len(m.Headers) == 0 {
+		return nil
+	}
+
+	out := make(http.Header, len(m.Headers))
+	for k, values := range m.Headers {
+		for _, v := range values {
+			out.Add(k, v)
+		}
+	}
+
+	return out
+}
+
+// BodyMatcher declares the conditions a request body must meet, every non-empty one must be satisfied.
+type BodyMatcher struct {
+	// JSON is compared against the whole body.
+	JSON any `json:"json,omitempty"`
+	// JSONPath maps paths like `$.people[0].name` to their expected value.
+	JSONPath map[string]any `json:"jsonPath,omitempty"`
+	// Regex must match the raw body.
+	Regex *regexp.Regexp `json:"regex,omitempty"`
+	// Form maps url encoded form fields to their expected value.
+	Form map[string]string `json:"form,omitempty"`
+}
+
+func (bm *BodyMatcher) Len() int {
+	if bm == nil {
+		return 0
+	}
+
+	out := len(bm.JSONPath) + len(bm.Form)
+	if bm.JSON != nil {
+		out++
+	}
+	if bm.Regex != nil {
+		out++
+	}
+
+	return out
+}
+
+// ClientMatcher declares the TLS client certificate a request must present, every non-empty condition must be satisfied.
+type ClientMatcher struct {
+	// CN is the expected subject common name, "*" matches any certificate.
+	CN string `json:"cn,omitempty"`
+	// SAN must be one of the DNS, email, IP or URI subject alternative names.
+	SAN string `json:"san,omitempty"`
+	// Verified requires the certificate to be signed by the client CA, or not to be if false.
+	Verified *bool `json:"verified,omitempty"`
+}
+
+func (cm *ClientMatcher) Len() int {
+	if cm == nil {
+		return 0
+	}
+
+	var out int
+	if cm.CN != "" {
+		out++
+	}
+	if cm.SAN != "" {
+		out++
+	}
+	if cm.Verified != nil {
+		out++
+	}
+
+	// requiring any certificate is a criterion too.
+	return max(out, 1)
+}
+
+func isMeta(path string) bool {
+	return strings.HasSuffix(path, metaSuffix)
+}
+
+func readMeta(path string) (*meta, error) {
+	b, err := os.ReadFile(filepath.Clean(path + metaSuffix))
+	if errors.Is(err, os.ErrNotExist) {
+		return &meta{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	var out meta
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	if out.Scenario != nil && out.Scenario.Name == "" {
+		return nil, errors.New("scenario name is empty")
+	}
+
+	return &out, nil
+}
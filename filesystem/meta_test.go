@@ -0,0 +1,39 @@
+package filesystem
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readMeta(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "people.json")
+
+	m, err := readMeta(path)
+	require.NoError(t, err)
+	assert.Nil(t, m.header())
+
+	content := `{"headers": {"location": "/api/people/1", "Set-Cookie": ["a=1", "b=2"]}}`
+	require.NoError(t, os.WriteFile(path+metaSuffix, []byte(content), 0o600))
+
+	m, err = readMeta(path)
+	require.NoError(t, err)
+	assert.Equal(t, http.Header{"Location": {"/api/people/1"}, "Set-Cookie": {"a=1", "b=2"}}, m.header())
+
+	require.NoError(t, os.WriteFile(path+metaSuffix, []byte(`{"headers": {"Location": 1}}`), 0o600))
+
+	_, err = readMeta(path)
+	assert.ErrorContains(t, err, "header values must be a string or an array of strings")
+
+	require.NoError(t, os.WriteFile(path+metaSuffix, []byte(`{"match": {"client": {"cn": "partner", "verified": true}}}`), 0o600))
+
+	m, err = readMeta(path)
+	require.NoError(t, err)
+	assert.Equal(t, "partner", m.Match.Client.CN)
+	assert.Equal(t, 2, m.Match.Client.Len())
+}
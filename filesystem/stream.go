@@ -0,0 +1,94 @@
+package filesystem
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/agukrapo/simpler-mock-server/internal/mime"
+)
+
+// EventStream is the type of the responses sent as Server-Sent Events.
+const EventStream mime.Type = "text/event-stream"
+
+// delayField is the non-standard event field setting how long to wait before sending the event, it isn't sent.
+const delayField = "delay:"
+
+// Event is a chunk of a streamed response file, either a Server-Sent Event or a line.
+type Event struct {
+	// Delay is how long to wait before sending the event.
+	Delay time.Duration
+	// Data is the event as sent, for Server-Sent Events its `id:`, `event:`, `data:` and `retry:` fields followed by a blank line.
+	Data []byte
+}
+
+// SplitLines reads the lines of a file streamed one at a time, each one interval after the previous one.
+func SplitLines(r io.Reader, interval time.Duration) ([]Event, error) {
+	var out []Event
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			event := Event{Data: line}
+			if len(out) > 0 {
+				event.Delay = interval
+			}
+			out = append(out, event)
+		}
+
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ParseEvents reads the blank line separated events of an event stream file.
+func ParseEvents(r io.Reader) ([]Event, error) {
+	var (
+		out   []Event
+		delay time.Duration
+		lines []string
+	)
+
+	flush := func() {
+		if len(lines) > 0 {
+			out = append(out, Event{Delay: delay, Data: []byte(strings.Join(lines, "\n") + "\n\n")})
+			delay, lines = 0, nil
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if line == "" {
+			flush()
+			continue
+		}
+
+		if value, ok := strings.CutPrefix(line, delayField); ok {
+			d, err := time.ParseDuration(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid event delay %q: %w", value, err)
+			}
+			delay = d
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	flush()
+
+	return out, nil
+}
@@ -0,0 +1,42 @@
+package filesystem
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEvents(t *testing.T) {
+	file := "id: 1\r\nevent: created\r\ndata: {\"id\":1}\r\n\r\n" +
+		"delay: 500ms\n: comment\ndata: first\ndata: second\n\n\n" +
+		"delay: 1s\n\n" +
+		"retry: 3000\n"
+
+	got, err := ParseEvents(strings.NewReader(file))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Event{
+		{Data: []byte("id: 1\nevent: created\ndata: {\"id\":1}\n\n")},
+		{Delay: 500 * time.Millisecond, Data: []byte(": comment\ndata: first\ndata: second\n\n")},
+		{Delay: time.Second, Data: []byte("retry: 3000\n\n")},
+	}, got)
+}
+
+func TestParseEvents_invalidDelay(t *testing.T) {
+	_, err := ParseEvents(strings.NewReader("delay: soon\ndata: x\n"))
+	assert.EqualError(t, err, `invalid event delay " soon": time: invalid duration "soon"`)
+}
+
+func TestSplitLines(t *testing.T) {
+	got, err := SplitLines(strings.NewReader("{\"n\":1}\n{\"n\":2}\n{\"n\":3}"), 100*time.Millisecond)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Event{
+		{Data: []byte("{\"n\":1}\n")},
+		{Delay: 100 * time.Millisecond, Data: []byte("{\"n\":2}\n")},
+		{Delay: 100 * time.Millisecond, Data: []byte(`{"n":3}`)},
+	}, got)
+}
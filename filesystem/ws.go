@@ -0,0 +1,94 @@
+package filesystem
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WebSocket is the method dir of the WebSocket routes, whose files are scripts describing the conversation.
+const WebSocket = "WS"
+
+// Script is a WebSocket conversation.
+type Script struct {
+	// OnConnect messages are sent as soon as the connection is established.
+	OnConnect []Message `json:"onConnect"`
+	// Replies answer incoming messages, the first one matching the message is used.
+	Replies []Reply `json:"replies"`
+	// Pushes are sent on their own schedule.
+	Pushes []Push `json:"pushes"`
+}
+
+// Reply sends its messages back when an incoming message matches, any message if Match is nil.
+type Reply struct {
+	Match *BodyMatcher `json:"match"`
+	Send  []Message    `json:"send"`
+}
+
+// Push sends its message After the connection is established, and then Every interval if set.
+type Push struct {
+	After time.Duration
+	Every time.Duration
+	Send  Message
+}
+
+func (p *Push) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		After string  `json:"after"`
+		Every string  `json:"every"`
+		Send  Message `json:"send"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	p.Send = raw.Send
+
+	var err error
+	if raw.After != "" {
+		if p.After, err = time.ParseDuration(raw.After); err != nil {
+			return fmt.Errorf("invalid push after: %w", err)
+		}
+	}
+	if raw.Every != "" {
+		if p.Every, err = time.ParseDuration(raw.Every); err != nil {
+			return fmt.Errorf("invalid push every: %w", err)
+		}
+		if p.Every <= 0 {
+			return fmt.Errorf("invalid push every %s", raw.Every)
+		}
+	}
+
+	return nil
+}
+
+// Message is a text message, a JSON string is sent verbatim and any other JSON value as JSON.
+type Message []byte
+
+func (m *Message) UnmarshalJSON(b []byte) error {
+	var text string
+	if err := json.Unmarshal(b, &text); err == nil {
+		*m = Message(text)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return err
+	}
+
+	*m = buf.Bytes()
+	return nil
+}
+
+// ParseScript reads the script of a WebSocket route file.
+func ParseScript(r io.Reader) (*Script, error) {
+	var out Script
+	if err := json.NewDecoder(r).Decode(&out); err != nil {
+		return nil, fmt.Errorf("json.Decode: %w", err)
+	}
+
+	return &out, nil
+}
@@ -0,0 +1,34 @@
+package filesystem
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScript(t *testing.T) {
+	script, err := ParseScript(strings.NewReader(`{
+		"onConnect": ["hello", {"type": "welcome"}],
+		"replies": [
+			{"match": {"jsonPath": {"$.type": "ping"}}, "send": [{"type": "pong"}]},
+			{"send": ["unknown"]}
+		],
+		"pushes": [{"after": "1s", "every": "500ms", "send": {"type": "tick"}}]
+	}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Message{Message("hello"), Message(`{"type":"welcome"}`)}, script.OnConnect)
+	require.Len(t, script.Replies, 2)
+	assert.Equal(t, map[string]any{"$.type": "ping"}, script.Replies[0].Match.JSONPath)
+	assert.Equal(t, []Message{Message(`{"type":"pong"}`)}, script.Replies[0].Send)
+	assert.Nil(t, script.Replies[1].Match)
+	assert.Equal(t, []Push{{After: time.Second, Every: 500 * time.Millisecond, Send: Message(`{"type":"tick"}`)}}, script.Pushes)
+}
+
+func TestParseScript_invalidPush(t *testing.T) {
+	_, err := ParseScript(strings.NewReader(`{"pushes": [{"every": "0s", "send": "tick"}]}`))
+	assert.EqualError(t, err, "json.Decode: invalid push every 0s")
+}
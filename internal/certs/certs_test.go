@@ -0,0 +1,30 @@
+package certs
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfSigned(t *testing.T) {
+	cert, ca, err := SelfSigned("mock.internal", "10.0.0.1")
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(ca))
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+
+	for _, host := range []string{"localhost", "127.0.0.1", "::1", "mock.internal", "10.0.0.1"} {
+		t.Run(host, func(t *testing.T) {
+			_, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: pool})
+			assert.NoError(t, err)
+		})
+	}
+
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: pool})
+	assert.Error(t, err)
+}
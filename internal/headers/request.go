@@ -1,16 +1,121 @@
 package headers
 
 import (
+	"cmp"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/agukrapo/simpler-mock-server/internal/mime"
 )
 
+// mediaRange is an Accept header element, like `text/*;q=0.5`.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func (mr mediaRange) specificity() int {
+	switch {
+	case mr.typ == "*":
+		return 0
+	case mr.subtype == "*":
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (mr mediaRange) matches(t mime.Type) bool {
+	typ, subtype, _ := strings.Cut(string(t), "/")
+	return (mr.typ == "*" || strings.EqualFold(mr.typ, typ)) && (mr.subtype == "*" || strings.EqualFold(mr.subtype, subtype))
+}
+
+func parseAccept(req *http.Request) []mediaRange {
+	var out []mediaRange
+	for _, value := range req.Header.Values("Accept") {
+		for _, element := range strings.Split(value, ",") {
+			params := strings.Split(element, ";")
+
+			typ, subtype, ok := strings.Cut(strings.TrimSpace(params[0]), "/")
+			if !ok || typ == "" || subtype == "" || typ == "*" && subtype != "*" {
+				continue
+			}
+
+			mr := mediaRange{typ: typ, subtype: subtype, q: 1}
+			for _, p := range params[1:] {
+				k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+				if strings.EqualFold(k, "q") {
+					if q, err := strconv.ParseFloat(v, 64); err == nil && q >= 0 && q <= 1 {
+						mr.q = q
+					}
+				}
+			}
+
+			out = append(out, mr)
+		}
+	}
+
+	return out
+}
+
+// Accept returns the concrete MIME type the request prefers the most, empty if it accepts any type.
 func Accept(req *http.Request) mime.Type {
-	if ct, _, _ := strings.Cut(req.Header.Get("Accept"), ","); ct != "*/*" {
-		return mime.Type(ct)
+	var best *mediaRange
+	for _, mr := range parseAccept(req) {
+		if mr.specificity() == 2 && mr.q > 0 && (best == nil || mr.q > best.q) {
+			best = &mr
+		}
+	}
+
+	if best == nil {
+		return ""
+	}
+
+	return mime.Type(best.typ + "/" + best.subtype)
+}
+
+// Rank returns the available MIME types the request accepts, from the most to the least preferred one,
+// as described in RFC 9110 section 12.5.1. Ties keep the available order.
+func Rank(req *http.Request, available []mime.Type) []mime.Type {
+	ranges := parseAccept(req)
+	if len(ranges) == 0 {
+		return slices.Clone(available)
+	}
+
+	type ranked struct {
+		typ         mime.Type
+		q           float64
+		specificity int
+	}
+
+	var out []ranked
+	for _, t := range available {
+		var best *mediaRange
+		for _, mr := range ranges {
+			if mr.matches(t) && (best == nil || mr.specificity() > best.specificity()) {
+				best = &mr
+			}
+		}
+
+		if best != nil && best.q > 0 {
+			out = append(out, ranked{typ: t, q: best.q, specificity: best.specificity()})
+		}
+	}
+
+	slices.SortStableFunc(out, func(a, b ranked) int {
+		if c := cmp.Compare(b.q, a.q); c != 0 {
+			return c
+		}
+
+		return b.specificity - a.specificity
+	})
+
+	types := make([]mime.Type, len(out))
+	for i, r := range out {
+		types[i] = r.typ
 	}
 
-	return ""
+	return types
 }
@@ -0,0 +1,64 @@
+package headers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agukrapo/simpler-mock-server/internal/mime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccept(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   mime.Type
+	}{
+		{"", ""},
+		{"*/*", ""},
+		{"application/json", "application/json"},
+		{"application/xml, application/json", "application/xml"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html"},
+		{"application/xml;q=0.5, text/csv", "text/csv"},
+		{"text/*, */*;q=0.1", ""},
+		{"application/json;q=0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			assert.Equal(t, tt.want, Accept(req))
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	available := []mime.Type{"application/json", "application/xml", "text/csv", "text/plain"}
+
+	tests := []struct {
+		accept string
+		want   []mime.Type
+	}{
+		{"", available},
+		{"*/*", available},
+		{"application/xml", []mime.Type{"application/xml"}},
+		{"application/xml, */*", []mime.Type{"application/xml", "application/json", "text/csv", "text/plain"}},
+		{"text/*;q=0.5, application/*;q=0.2", []mime.Type{"text/csv", "text/plain", "application/json", "application/xml"}},
+		{"text/*, text/plain;q=0", []mime.Type{"text/csv"}},
+		{"application/json;q=0.1, application/xml;q=0.9", []mime.Type{"application/xml", "application/json"}},
+		{"APPLICATION/JSON; charset=utf-8", []mime.Type{"application/json"}},
+		{"image/png", nil},
+		{"invalid", available},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			got := Rank(req, available)
+			if len(tt.want) == 0 {
+				assert.Empty(t, got)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Get returns the value found at path in a decoded JSON document.
+// Only the dot notation and array indexes are supported, e.g. `$.people[0].name`.
+func Get(doc any, path string) (any, error) {
+	steps, err := parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	out := doc
+	for _, step := range steps {
+		switch s := step.(type) {
+		case string:
+			obj, ok := out.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: %q is not an object key", path, s)
+			}
+
+			if out, ok = obj[s]; !ok {
+				return nil, fmt.Errorf("%s: key %q not found", path, s)
+			}
+		case int:
+			arr, ok := out.([]any)
+			if !ok || s < 0 || s >= len(arr) {
+				return nil, fmt.Errorf("%s: index %d not found", path, s)
+			}
+
+			out = arr[s]
+		}
+	}
+
+	return out, nil
+}
+
+func parse(path string) ([]any, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("%s: path must start with $", path)
+	}
+
+	var out []any
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("%s: empty key", path)
+			}
+
+			out = append(out, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("%s: unclosed bracket", path)
+			}
+
+			inner := rest[1:end]
+			if key, ok := quoted(inner); ok {
+				out = append(out, key)
+			} else {
+				i, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("%s: invalid index %q", path, inner)
+				}
+				out = append(out, i)
+			}
+
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("%s: unexpected %q", path, rest[0])
+		}
+	}
+
+	return out, nil
+}
+
+func quoted(in string) (string, bool) {
+	if len(in) >= 2 && (in[0] == '\'' || in[0] == '"') && in[len(in)-1] == in[0] {
+		return in[1 : len(in)-1], true
+	}
+
+	return "", false
+}
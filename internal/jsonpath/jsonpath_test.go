@@ -0,0 +1,42 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGet(t *testing.T) {
+	var doc any
+	require.NoError(t, json.Unmarshal([]byte(`{"name":"John","pets":[{"name":"Rex"}],"a.b":true}`), &doc))
+
+	tests := []struct {
+		path    string
+		want    any
+		wantErr string
+	}{
+		{"$.name", "John", ""},
+		{"$.pets[0].name", "Rex", ""},
+		{"$['a.b']", true, ""},
+		{"$.pets", []any{map[string]any{"name": "Rex"}}, ""},
+		{"$", doc, ""},
+		{"$.missing", nil, `$.missing: key "missing" not found`},
+		{"$.pets[1]", nil, "$.pets[1]: index 1 not found"},
+		{"$.name.first", nil, `$.name.first: "first" is not an object key`},
+		{"name", nil, "name: path must start with $"},
+		{"$.pets[x]", nil, `$.pets[x]: invalid index "x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := Get(doc, tt.path)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package latency
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Distribution generates response delays.
+type Distribution interface {
+	Next() time.Duration
+	String() string
+}
+
+// Fixed always delays the same duration, like `3s`.
+type Fixed time.Duration
+
+func (f Fixed) Next() time.Duration {
+	return time.Duration(f)
+}
+
+func (f Fixed) String() string {
+	return time.Duration(f).String()
+}
+
+// Uniform delays any duration between Min and Max with the same probability, like `100ms-500ms`.
+type Uniform struct {
+	Min, Max time.Duration
+}
+
+func (u Uniform) Next() time.Duration {
+	return u.Min + rand.N(u.Max-u.Min+1)
+}
+
+func (u Uniform) String() string {
+	return u.Min.String() + "-" + u.Max.String()
+}
+
+// Normal delays durations normally distributed around Mean, like `200ms~50ms`, never less than zero.
+type Normal struct {
+	Mean, StdDev time.Duration
+}
+
+func (n Normal) Next() time.Duration {
+	return max(0, n.Mean+time.Duration(rand.NormFloat64()*float64(n.StdDev)))
+}
+
+func (n Normal) String() string {
+	return n.Mean.String() + "~" + n.StdDev.String()
+}
+
+type percentile struct {
+	rank  float64
+	value time.Duration
+}
+
+// Percentiles delays durations following a latency profile, like `p50_100ms+p99_2s`.
+// Durations are interpolated between consecutive percentiles, starting from 0 at p0, and the ones above the last percentile
+// are its value.
+type Percentiles []percentile
+
+func (p Percentiles) Next() time.Duration {
+	r := rand.Float64() * 100
+
+	prev := percentile{}
+	for _, cur := range p {
+		if r <= cur.rank {
+			ratio := (r - prev.rank) / (cur.rank - prev.rank)
+			return prev.value + time.Duration(ratio*float64(cur.value-prev.value))
+		}
+		prev = cur
+	}
+
+	return prev.value
+}
+
+func (p Percentiles) String() string {
+	parts := make([]string, len(p))
+	for i, cur := range p {
+		parts[i] = "p" + strconv.FormatFloat(cur.rank, 'f', -1, 64) + "_" + cur.value.String()
+	}
+
+	return strings.Join(parts, "+")
+}
+
+// Parse reads a distribution: a fixed duration, a uniform `{min}-{max}` range, a normal `{mean}~{stddev}` one
+// or `p{rank}_{duration}` percentiles joined by `+`.
+func Parse(s string) (Distribution, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return Fixed(d), nil
+	}
+
+	if lo, hi, ok := strings.Cut(s, "-"); ok {
+		return parseUniform(lo, hi)
+	}
+
+	if mean, stddev, ok := strings.Cut(s, "~"); ok {
+		return parseNormal(mean, stddev)
+	}
+
+	if strings.HasPrefix(s, "p") {
+		return parsePercentiles(s)
+	}
+
+	return nil, fmt.Errorf("invalid latency %s", s)
+}
+
+func parseUniform(lo, hi string) (Distribution, error) {
+	minimum, err := time.ParseDuration(lo)
+	if err != nil {
+		return nil, err
+	}
+
+	maximum, err := time.ParseDuration(hi)
+	if err != nil {
+		return nil, err
+	}
+
+	if minimum < 0 || maximum < minimum {
+		return nil, fmt.Errorf("invalid latency range %s-%s", lo, hi)
+	}
+
+	return Uniform{Min: minimum, Max: maximum}, nil
+}
+
+func parseNormal(mean, stddev string) (Distribution, error) {
+	m, err := time.ParseDuration(mean)
+	if err != nil {
+		return nil, err
+	}
+
+	sd, err := time.ParseDuration(stddev)
+	if err != nil {
+		return nil, err
+	}
+
+	if m < 0 || sd < 0 {
+		return nil, fmt.Errorf("invalid latency distribution %s~%s", mean, stddev)
+	}
+
+	return Normal{Mean: m, StdDev: sd}, nil
+}
+
+func parsePercentiles(s string) (Distribution, error) {
+	var out Percentiles
+	for _, part := range strings.Split(s, "+") {
+		rank, value, ok := strings.Cut(strings.TrimPrefix(part, "p"), "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid latency percentile %s", part)
+		}
+
+		r, err := strconv.ParseFloat(rank, 64)
+		if err != nil || r <= 0 || r > 100 {
+			return nil, fmt.Errorf("invalid latency percentile %s", part)
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, percentile{rank: r, value: d})
+	}
+
+	slices.SortFunc(out, func(a, b percentile) int {
+		return cmp.Compare(a.rank, b.rank)
+	})
+
+	for i := 1; i < len(out); i++ {
+		if out[i].rank == out[i-1].rank || out[i].value < out[i-1].value {
+			return nil, fmt.Errorf("invalid latency percentiles %s", s)
+		}
+	}
+
+	return out, nil
+}
+
+// Sum adds the delays of the distributions, ignoring nil ones.
+func Sum(dists ...Distribution) time.Duration {
+	var out time.Duration
+	for _, d := range dists {
+		if d != nil {
+			out += d.Next()
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,85 @@
+package latency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Distribution
+		wantErr string
+	}{
+		{"3s", Fixed(3 * time.Second), ""},
+		{"100ms-500ms", Uniform{Min: 100 * time.Millisecond, Max: 500 * time.Millisecond}, ""},
+		{"200ms~50ms", Normal{Mean: 200 * time.Millisecond, StdDev: 50 * time.Millisecond}, ""},
+		{"p99_2s+p50_100ms", Percentiles{{50, 100 * time.Millisecond}, {99, 2 * time.Second}}, ""},
+		{"500ms-100ms", nil, "invalid latency range 500ms-100ms"},
+		{"p50_1s+p99_100ms", nil, "invalid latency percentiles p50_1s+p99_100ms"},
+		{"p101_1s", nil, "invalid latency percentile p101_1s"},
+		{"p50", nil, "invalid latency percentile p50"},
+		{"nonsense", nil, "invalid latency nonsense"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := Parse(tt.s)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want, must(Parse(got.String())))
+		})
+	}
+}
+
+func TestDistribution_Next(t *testing.T) {
+	tests := []struct {
+		s        string
+		min, max time.Duration
+	}{
+		{"3s", 3 * time.Second, 3 * time.Second},
+		{"100ms-500ms", 100 * time.Millisecond, 500 * time.Millisecond},
+		{"200ms~50ms", 0, time.Second},
+		{"p50_100ms+p99_2s", 0, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			d := must(Parse(tt.s))
+			for range 1000 {
+				got := d.Next()
+				assert.GreaterOrEqual(t, got, tt.min)
+				assert.LessOrEqual(t, got, tt.max)
+			}
+		})
+	}
+}
+
+func TestPercentiles_Next(t *testing.T) {
+	d := must(Parse("p50_100ms+p99_2s"))
+
+	var below int
+	for range 10000 {
+		if d.Next() <= 100*time.Millisecond {
+			below++
+		}
+	}
+
+	assert.InDelta(t, 5000, below, 300)
+}
+
+func TestSum(t *testing.T) {
+	assert.Equal(t, 3*time.Second, Sum(Fixed(time.Second), nil, Fixed(2*time.Second)))
+	assert.Zero(t, Sum())
+}
+
+func must(d Distribution, err error) Distribution {
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
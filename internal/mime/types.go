@@ -57,6 +57,9 @@ func defaults() *bimap.M[Extension, Type] {
 	out.Put("xml", "application/xml")
 	out.Put("html", "text/html")
 	out.Put("csv", "text/csv")
+	out.Put("sse", "text/event-stream")
+	out.Put("ndjson", "application/x-ndjson")
+	out.Put("jsonl", "application/jsonl")
 
 	return out
 }
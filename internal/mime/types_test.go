@@ -18,6 +18,9 @@ func TestTypes_Extension(t *testing.T) {
 		{"application/xml", "xml"},
 		{"text/html", "html"},
 		{"text/csv", "csv"},
+		{"text/event-stream", "sse"},
+		{"application/x-ndjson", "ndjson"},
+		{"application/jsonl", "jsonl"},
 		{"bar", "foo"},
 		{"", "json"},
 	}
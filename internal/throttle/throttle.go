@@ -0,0 +1,147 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tick is about how often a bytes per second Rate writes a chunk, so bodies trickle instead of bursting every second.
+const tick = 100 * time.Millisecond
+
+var units = []struct {
+	suffix string
+	size   int
+}{
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// Rate limits writes to a chunk of bytes every interval.
+type Rate struct {
+	Chunk    int
+	Interval time.Duration
+
+	spec string
+}
+
+// Parse reads a rate, either bytes per second like `64KBps` or a chunk every interval like `chunk-1KB-500ms`.
+func Parse(s string) (*Rate, error) {
+	if spec, ok := strings.CutPrefix(s, "chunk-"); ok {
+		size, interval, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid throttle %s", s)
+		}
+
+		chunk, err := parseSize(size)
+		if err != nil {
+			return nil, err
+		}
+
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid throttle interval %s", interval)
+		}
+
+		return &Rate{Chunk: chunk, Interval: d, spec: s}, nil
+	}
+
+	if size, ok := strings.CutSuffix(s, "ps"); ok {
+		bps, err := parseSize(size)
+		if err != nil {
+			return nil, err
+		}
+
+		chunk := max(1, bps*int(tick)/int(time.Second))
+		return &Rate{Chunk: chunk, Interval: time.Duration(chunk) * time.Second / time.Duration(bps), spec: s}, nil
+	}
+
+	return nil, fmt.Errorf("invalid throttle %s", s)
+}
+
+func parseSize(s string) (int, error) {
+	for _, unit := range units {
+		if n, ok := strings.CutSuffix(s, unit.suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v <= 0 {
+				return 0, fmt.Errorf("invalid throttle size %s", s)
+			}
+
+			return v * unit.size, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid throttle size %s", s)
+}
+
+func (r *Rate) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+
+	*r = *parsed
+	return nil
+}
+
+func (r *Rate) String() string {
+	return r.spec
+}
+
+type flusher interface {
+	Flush()
+}
+
+type writer struct {
+	ctx     context.Context
+	w       io.Writer
+	rate    *Rate
+	written bool
+}
+
+// Writer writes to w at the rate, flushing every chunk if w can, until ctx is done.
+func (r *Rate) Writer(ctx context.Context, w io.Writer) io.Writer {
+	return &writer{ctx: ctx, w: w, rate: r}
+}
+
+func (tw *writer) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		if tw.written {
+			if err := tw.wait(); err != nil {
+				return n, err
+			}
+		}
+
+		m, err := tw.w.Write(p[:min(len(p), tw.rate.Chunk)])
+		n += m
+		tw.written = true
+		if err != nil {
+			return n, err
+		}
+
+		if f, ok := tw.w.(flusher); ok {
+			f.Flush()
+		}
+
+		p = p[m:]
+	}
+
+	return n, nil
+}
+
+func (tw *writer) wait() error {
+	timer := time.NewTimer(tw.rate.Interval)
+	defer timer.Stop()
+
+	select {
+	case <-tw.ctx.Done():
+		return tw.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,86 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s            string
+		wantChunk    int
+		wantInterval time.Duration
+		wantErr      string
+	}{
+		{"64KBps", 6553, 6553 * time.Second / (64 << 10), ""},
+		{"1MBps", 104857, 104857 * time.Second / (1 << 20), ""},
+		{"10Bps", 1, tick, ""},
+		{"5Bps", 1, 200 * time.Millisecond, ""},
+		{"chunk-1KB-500ms", 1 << 10, 500 * time.Millisecond, ""},
+		{"chunk-10B-1s", 10, time.Second, ""},
+		{"chunk-1KB", 0, 0, "invalid throttle chunk-1KB"},
+		{"chunk-1KB-0s", 0, 0, "invalid throttle interval 0s"},
+		{"0KBps", 0, 0, "invalid throttle size 0KB"},
+		{"64GBps", 0, 0, "invalid throttle size 64GB"},
+		{"nonsense", 0, 0, "invalid throttle nonsense"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := Parse(tt.s)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantChunk, got.Chunk)
+			assert.Equal(t, tt.wantInterval, got.Interval)
+			assert.Equal(t, tt.s, got.String())
+		})
+	}
+}
+
+type flushRecorder struct {
+	bytes.Buffer
+	chunks []string
+}
+
+func (f *flushRecorder) Flush() {
+	f.chunks = append(f.chunks, f.String())
+	f.Reset()
+}
+
+func TestRate_Writer(t *testing.T) {
+	r, err := Parse("chunk-4B-10ms")
+	require.NoError(t, err)
+
+	var rec flushRecorder
+	start := time.Now()
+
+	n, err := r.Writer(context.Background(), &rec).Write([]byte("0123456789"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 10, n)
+	assert.Equal(t, []string{"0123", "4567", "89"}, rec.chunks)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestRate_Writer_canceled(t *testing.T) {
+	r, err := Parse("chunk-1B-1h")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var rec strings.Builder
+	n, err := r.Writer(ctx, &rec).Write([]byte("01"))
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "0", rec.String())
+}
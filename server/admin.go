@@ -0,0 +1,150 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+func (s *Server) mux() http.Handler {
+	admin := http.NewServeMux()
+	admin.HandleFunc("GET "+s.adminPrefix+"routes", s.handleRoutes)
+	admin.HandleFunc("POST "+s.adminPrefix+"refresh", s.handleRefresh)
+	admin.HandleFunc("POST "+s.adminPrefix+"reset", s.handleReset)
+	admin.HandleFunc("POST "+s.adminPrefix+"scenarios/reset", s.handleResetScenarios)
+	admin.HandleFunc("GET "+s.adminPrefix+"requests", s.handleRequests)
+	admin.HandleFunc("DELETE "+s.adminPrefix+"requests", s.handleClearRequests)
+	admin.HandleFunc("POST "+s.adminPrefix+"requests/verify", s.handleVerify)
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, s.adminPrefix) {
+			admin.ServeHTTP(writer, req)
+			return
+		}
+
+		if isWebSocket(req) {
+			s.upgrade(writer, req)
+			return
+		}
+
+		s.handle(writer, req)
+	})
+}
+
+// Routes returns the descriptors of every known route.
+func (s *Server) Routes() []map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]map[string]interface{}, 0)
+	for _, d := range s.routes {
+		for _, vs := range d {
+			for _, v := range vs {
+				for _, desc := range v.descs {
+					out = append(out, fieldsFromDescriptor(desc))
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(out, func(a, b map[string]interface{}) int {
+		return cmp.Compare(a["path"].(string), b["path"].(string))
+	})
+
+	return out
+}
+
+// ResetScenarios moves every scenario back to the "Started" state.
+func (s *Server) ResetScenarios() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.scenarios)
+	log.Info().Msg("Scenarios reset")
+}
+
+// ResetSequences makes every sequence start over.
+func (s *Server) ResetSequences() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range s.routes {
+		for _, vs := range d {
+			for _, v := range vs {
+				v.calls = 0
+			}
+		}
+	}
+	log.Info().Msg("Sequences reset")
+}
+
+func (s *Server) handleRoutes(writer http.ResponseWriter, _ *http.Request) {
+	writeJSON(writer, http.StatusOK, s.Routes())
+}
+
+func (s *Server) handleRefresh(writer http.ResponseWriter, _ *http.Request) {
+	if err := s.refresh(); err != nil {
+		log.Error().Err(err).Msg("Routes refresh failed")
+		writeJSON(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleReset(writer http.ResponseWriter, _ *http.Request) {
+	s.ResetScenarios()
+	s.ResetSequences()
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleResetScenarios(writer http.ResponseWriter, _ *http.Request) {
+	s.ResetScenarios()
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRequests(writer http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	writeJSON(writer, http.StatusOK, s.Journal(Criteria{
+		Method: q.Get("method"),
+		Path:   q.Get("path"),
+		Body:   q.Get("body"),
+	}))
+}
+
+func (s *Server) handleClearRequests(writer http.ResponseWriter, _ *http.Request) {
+	s.ClearJournal()
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleVerify(writer http.ResponseWriter, req *http.Request) {
+	var in struct {
+		Criteria
+		Count int `json:"count"`
+	}
+
+	if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := s.Verify(in.Criteria, in.Count); err != nil {
+		writeJSON(writer, http.StatusExpectationFailed, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(writer http.ResponseWriter, status int, v any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+
+	if err := json.NewEncoder(writer).Encode(v); err != nil {
+		log.Error().Err(err).Msg("JSON encoding failed")
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_admin(t *testing.T) {
+	first := descriptor(http.MethodGet, "/job", http.StatusAccepted, "pending")
+	first.Path = "GET/1.202___job.json"
+	first.Sequence = 1
+	second := descriptor(http.MethodGet, "/job", http.StatusOK, "done")
+	second.Path = "GET/2___job.json"
+	second.Sequence = 2
+
+	s := newTestServer(t, first, second)
+	WithAdminPrefix("admin")(s)
+	s.s.Handler = s.mux()
+
+	status, body := do(t, s, http.MethodGet, "/admin/routes", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `[
+		{"method":"GET","route":"/job","status":202,"path":"GET/1.202___job.json","type":"application/json","sequence":1},
+		{"method":"GET","route":"/job","status":200,"path":"GET/2___job.json","type":"application/json","sequence":2}
+	]`, body)
+
+	_, body = do(t, s, http.MethodGet, "/job", "")
+	assert.Equal(t, "pending", body)
+	_, body = do(t, s, http.MethodGet, "/job", "")
+	assert.Equal(t, "done", body)
+
+	status, _ = do(t, s, http.MethodPost, "/admin/reset", "")
+	assert.Equal(t, http.StatusNoContent, status)
+
+	_, body = do(t, s, http.MethodGet, "/job", "")
+	assert.Equal(t, "pending", body)
+
+	status, _ = do(t, s, http.MethodPost, "/admin/refresh", "")
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, _ = do(t, s, http.MethodGet, "/admin/nope", "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestWithAdminPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"admin", "/admin/"},
+		{"/api/admin/", "/api/admin/"},
+		{"/", "/__sms/"},
+		{"", "/__sms/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			s := New("", &fakeFS{}, WithAdminPrefix(tt.prefix))
+			assert.Equal(t, tt.want, s.adminPrefix)
+			assert.NotPanics(t, func() { s.mux() })
+		})
+	}
+}
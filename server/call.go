@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxBodySize limits the request bodies read into memory, for body criteria and templates.
+const maxBodySize = 10 << 20
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// call is a received request, whose body is read into memory only when body criteria or templates need it.
+type call struct {
+	*http.Request
+	body     []byte
+	buffered bool
+	captured *capture
+	params   map[string]string
+
+	jsonParsed bool
+	json       any
+	form       url.Values
+
+	// clientCAs verifies client certificates the TLS handshake didn't.
+	clientCAs *x509.CertPool
+}
+
+func newCall(req *http.Request) *call {
+	captured := &capture{ReadCloser: req.Body, sum: sha256.New()}
+	req.Body = captured
+
+	return &call{
+		Request:  req,
+		captured: captured,
+	}
+}
+
+// readBody reads the whole body into memory, the request keeps a copy of it so it can still be forwarded.
+func (c *call) readBody() error {
+	if c.buffered {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Body, maxBodySize+1))
+	if err != nil {
+		return fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	if len(body) > maxBodySize {
+		return errBodyTooLarge
+	}
+
+	c.body, c.buffered = body, true
+	c.Body = io.NopCloser(bytes.NewReader(body))
+
+	return nil
+}
+
+// readFailed responds to a request whose body couldn't be read, returning the response status.
+func readFailed(writer http.ResponseWriter, err error) int {
+	log.Error().Err(err).Msg("Reading request failed")
+
+	status := http.StatusBadRequest
+	if errors.Is(err, errBodyTooLarge) {
+		status = http.StatusRequestEntityTooLarge
+	}
+
+	http.Error(writer, http.StatusText(status), status)
+	return status
+}
+
+// capture keeps the start of a request body for the journal while it is read, and a hash of all of it.
+type capture struct {
+	io.ReadCloser
+	head []byte
+	size int
+	sum  hash.Hash
+}
+
+func (c *capture) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if room := maxEntryBody - len(c.head); room > 0 {
+		c.head = append(c.head, p[:min(n, room)]...)
+	}
+	c.size += n
+	c.sum.Write(p[:n])
+
+	return n, err
+}
+
+// drain reads what is left of the body, discarding all but the start the journal keeps.
+func (c *capture) drain() {
+	_, _ = io.Copy(io.Discard, c)
+}
+
+func (c *call) jsonBody() (any, bool) {
+	if !c.jsonParsed {
+		c.jsonParsed = true
+		if err := json.Unmarshal(c.body, &c.json); err != nil {
+			c.json = nil
+		}
+	}
+
+	return c.json, c.json != nil
+}
+
+func (c *call) formBody() url.Values {
+	if c.form == nil {
+		c.form, _ = url.ParseQuery(string(c.body))
+	}
+
+	return c.form
+}
+
+// clientCert returns the TLS client certificate of the request, if any, and whether it is signed by the client CA.
+func (c *call) clientCert() (*x509.Certificate, bool) {
+	if c.TLS == nil || len(c.TLS.PeerCertificates) == 0 {
+		return nil, false
+	}
+
+	cert := c.TLS.PeerCertificates[0]
+	if len(c.TLS.VerifiedChains) != 0 {
+		return cert, true
+	}
+
+	if c.clientCAs == nil {
+		return cert, false
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, ic := range c.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(ic)
+	}
+
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         c.clientCAs,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	return cert, err == nil
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/agukrapo/simpler-mock-server/internal/headers"
+	"github.com/agukrapo/simpler-mock-server/internal/mime"
+)
+
+// variant holds the descriptors of a route sharing type and request criteria, more than one of them form a sequence.
+type variant struct {
+	descs []*filesystem.Descriptor
+	end   filesystem.SequenceEnd
+	calls int
+}
+
+// next returns the descriptor for the current call, nil if the sequence ended.
+func (v *variant) next() *filesystem.Descriptor {
+	i := v.calls
+	v.calls++
+
+	if i < len(v.descs) {
+		return v.descs[i]
+	}
+
+	switch v.end {
+	case filesystem.Cycle:
+		return v.descs[i%len(v.descs)]
+	case filesystem.NotFound:
+		return nil
+	default:
+		return v.descs[len(v.descs)-1]
+	}
+}
+
+func (v *variant) add(desc *filesystem.Descriptor) bool {
+	for _, existing := range v.descs {
+		if existing.Sequence == 0 || desc.Sequence == 0 || existing.Sequence == desc.Sequence {
+			return false
+		}
+	}
+
+	v.descs = append(v.descs, desc)
+	slices.SortStableFunc(v.descs, func(a, b *filesystem.Descriptor) int {
+		return a.Sequence - b.Sequence
+	})
+
+	if desc.SequenceEnd != "" {
+		v.end = desc.SequenceEnd
+	}
+
+	return true
+}
+
+type variants []*variant
+
+// find returns the first variant matching the call.
+func (vs variants) find(c *call, sc scenarios) *variant {
+	for _, v := range vs {
+		if matches(v.descs[0], c) && sc.allows(v.descs[0]) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func (vs variants) hasDefault() bool {
+	for _, v := range vs {
+		if slices.ContainsFunc(v.descs, func(desc *filesystem.Descriptor) bool { return desc.Default }) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type dir map[mime.Type]variants
+
+func (d dir) hasBodyCriteria() bool {
+	for _, vs := range d {
+		for _, v := range vs {
+			if v.descs[0].MatchBody != nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (d dir) add(desc *filesystem.Descriptor, end filesystem.SequenceEnd) bool {
+	vs := d[desc.Type]
+	for _, v := range vs {
+		if criteria(v.descs[0]) == criteria(desc) {
+			return v.add(desc)
+		}
+	}
+
+	v := &variant{end: end}
+	if !v.add(desc) {
+		return false
+	}
+
+	vs = append(vs, v)
+	slices.SortStableFunc(vs, func(a, b *variant) int {
+		return specificity(b.descs[0]) - specificity(a.descs[0])
+	})
+	d[desc.Type] = vs
+
+	return true
+}
+
+// resolveVariant returns the variant matching the call of the type the call accepts the most.
+// notAcceptable reports whether some variant matched the call but the call accepts none of their types.
+func (d dir) resolveVariant(c *call, sc scenarios, order []mime.Type) (v *variant, notAcceptable bool) {
+	for _, t := range headers.Rank(c.Request, d.types(order)) {
+		if v := d[t].find(c, sc); v != nil {
+			return v, false
+		}
+	}
+
+	for _, vs := range d {
+		if vs.find(c, sc) != nil {
+			return nil, true
+		}
+	}
+
+	return nil, false
+}
+
+// types returns the types of the dir by precedence: the ones having a default descriptor first,
+// then the ones in order and the rest alphabetically.
+func (d dir) types(order []mime.Type) []mime.Type {
+	rank := func(t mime.Type) int {
+		if d[t].hasDefault() {
+			return -1
+		}
+		if i := slices.Index(order, t); i >= 0 {
+			return i
+		}
+		return len(order)
+	}
+
+	return slices.SortedStableFunc(slices.Values(slices.Sorted(maps.Keys(d))), func(a, b mime.Type) int {
+		return rank(a) - rank(b)
+	})
+}
+
+// variantKey identifies a variant across refreshes.
+type variantKey struct {
+	route    route
+	typ      mime.Type
+	criteria string
+}
+
+// sequenceCalls returns how many times every variant of the routes was called.
+func sequenceCalls(routes map[route]dir) map[variantKey]int {
+	out := make(map[variantKey]int)
+	for r, d := range routes {
+		for typ, vs := range d {
+			for _, v := range vs {
+				out[variantKey{route: r, typ: typ, criteria: criteria(v.descs[0])}] = v.calls
+			}
+		}
+	}
+
+	return out
+}
+
+// restoreCalls sets the call count of the variants of the routes, so refreshes don't restart their sequences.
+func restoreCalls(routes map[route]dir, calls map[variantKey]int) {
+	for r, d := range routes {
+		for typ, vs := range d {
+			for _, v := range vs {
+				v.calls = calls[variantKey{route: r, typ: typ, criteria: criteria(v.descs[0])}]
+			}
+		}
+	}
+}
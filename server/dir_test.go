@@ -0,0 +1,78 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/agukrapo/simpler-mock-server/internal/mime"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_variant_next(t *testing.T) {
+	tests := []struct {
+		end  filesystem.SequenceEnd
+		want []int
+	}{
+		{filesystem.RepeatLast, []int{1, 2, 3, 3, 3}},
+		{filesystem.Cycle, []int{1, 2, 3, 1, 2}},
+		{filesystem.NotFound, []int{1, 2, 3, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.end), func(t *testing.T) {
+			v := &variant{end: tt.end}
+			for _, seq := range []int{3, 1, 2} {
+				assert.True(t, v.add(&filesystem.Descriptor{Sequence: seq}))
+			}
+
+			var got []int
+			for range tt.want {
+				if desc := v.next(); desc != nil {
+					got = append(got, desc.Sequence)
+				} else {
+					got = append(got, 0)
+				}
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_variant_add(t *testing.T) {
+	v := &variant{}
+	assert.True(t, v.add(&filesystem.Descriptor{Sequence: 1}))
+	assert.False(t, v.add(&filesystem.Descriptor{Sequence: 1}))
+	assert.False(t, v.add(&filesystem.Descriptor{}))
+
+	v = &variant{}
+	assert.True(t, v.add(&filesystem.Descriptor{}))
+	assert.False(t, v.add(&filesystem.Descriptor{Sequence: 1}))
+}
+
+func Test_dir_types(t *testing.T) {
+	newDir := func(def mime.Type, types ...mime.Type) dir {
+		d := make(dir)
+		for _, typ := range types {
+			d.add(&filesystem.Descriptor{Type: typ, Default: typ == def}, filesystem.RepeatLast)
+		}
+		return d
+	}
+
+	tests := []struct {
+		name  string
+		d     dir
+		order []mime.Type
+		want  []mime.Type
+	}{
+		{"alphabetical", newDir("", "text/html", "application/xml", "application/json"), nil, []mime.Type{"application/json", "application/xml", "text/html"}},
+		{"order", newDir("", "text/html", "application/xml", "application/json"), []mime.Type{"text/html", "application/xml"}, []mime.Type{"text/html", "application/xml", "application/json"}},
+		{"default", newDir("application/xml", "text/html", "application/xml", "application/json"), []mime.Type{"text/html"}, []mime.Type{"application/xml", "text/html", "application/json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 10 {
+				assert.Equal(t, tt.want, tt.d.types(tt.order))
+			}
+		})
+	}
+}
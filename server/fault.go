@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+)
+
+const garbageSize = 1024
+
+var errNotHijackable = errors.New("connection can't be hijacked")
+
+// inject breaks the response of the descriptor as its fault says, writing straight to the hijacked connection.
+func inject(writer http.ResponseWriter, desc *filesystem.Descriptor, header http.Header, reader io.Reader) error {
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	hijacker, ok := writer.(http.Hijacker)
+	if !ok {
+		return errNotHijackable
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return fmt.Errorf("hijacker.Hijack: %w", err)
+	}
+	defer conn.Close()
+
+	switch desc.Fault.Kind {
+	case filesystem.FaultReset:
+		raw := conn
+		if tc, ok := conn.(*tls.Conn); ok {
+			// closing the TLS connection would send a close notify alert before the reset.
+			raw = tc.NetConn()
+		}
+		if tcp, ok := raw.(*net.TCPConn); ok {
+			if err := tcp.SetLinger(0); err != nil {
+				return err
+			}
+			return tcp.Close()
+		}
+		return nil
+	case filesystem.FaultEmpty:
+		return nil
+	case filesystem.FaultGarbage:
+		if _, err := io.CopyN(buf, rand.Reader, garbageSize); err != nil {
+			return err
+		}
+	case filesystem.FaultCloseAfterHeaders:
+		if err := writeHead(buf, desc, header, len(body)); err != nil {
+			return err
+		}
+	case filesystem.FaultTruncate:
+		if err := writeHead(buf, desc, header, len(body)); err != nil {
+			return err
+		}
+		if _, err := buf.Write(body[:len(body)*desc.Fault.Percent/100]); err != nil {
+			return err
+		}
+	}
+
+	return buf.Flush()
+}
+
+// writeHead writes the status line and headers of a response announcing the full body length.
+func writeHead(buf *bufio.ReadWriter, desc *filesystem.Descriptor, header http.Header, length int) error {
+	head := header.Clone()
+	if head == nil {
+		head = make(http.Header)
+	}
+	head.Set("Content-Type", string(desc.Type))
+	head.Set("Content-Length", strconv.Itoa(length))
+
+	if _, err := fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\n", desc.Status, http.StatusText(desc.Status)); err != nil {
+		return err
+	}
+	if err := head.Write(buf); err != nil {
+		return err
+	}
+	_, err := buf.WriteString("\r\n")
+	return err
+}
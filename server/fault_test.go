@@ -0,0 +1,87 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_fault(t *testing.T) {
+	tests := []struct {
+		fault       filesystem.Fault
+		wantRespErr bool
+		wantBody    string
+	}{
+		{filesystem.Fault{Kind: filesystem.FaultReset}, true, ""},
+		{filesystem.Fault{Kind: filesystem.FaultEmpty}, true, ""},
+		{filesystem.Fault{Kind: filesystem.FaultGarbage}, true, ""},
+		{filesystem.Fault{Kind: filesystem.FaultCloseAfterHeaders}, false, ""},
+		{filesystem.Fault{Kind: filesystem.FaultTruncate, Percent: 50}, false, "0123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fault.String(), func(t *testing.T) {
+			desc := descriptor(http.MethodGet, "/faulty", http.StatusOK, "01234567")
+			desc.Fault = &tt.fault
+
+			s := newTestServer(t, desc)
+			ts := httptest.NewServer(s.s.Handler)
+			defer ts.Close()
+
+			res, err := ts.Client().Get(ts.URL + "/faulty")
+			if tt.wantRespErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				defer res.Body.Close()
+
+				assert.Equal(t, http.StatusOK, res.StatusCode)
+				assert.Equal(t, int64(8), res.ContentLength)
+
+				body, err := io.ReadAll(res.Body)
+				assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+				assert.Equal(t, tt.wantBody, string(body))
+			}
+
+			// the journal entry is added once the handler returns, after the connection was closed.
+			require.Eventually(t, func() bool {
+				return len(s.Journal(Criteria{})) == 1
+			}, time.Second, 10*time.Millisecond)
+			assert.Equal(t, tt.fault.String()+" fault injected", s.Journal(Criteria{})[0].Error)
+		})
+	}
+}
+
+func TestServer_fault_notHijackable(t *testing.T) {
+	desc := descriptor(http.MethodGet, "/faulty", http.StatusOK, "01234567")
+	desc.Fault = &filesystem.Fault{Kind: filesystem.FaultReset}
+	s := newTestServer(t, desc)
+
+	rec := httptest.NewRecorder()
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		s.s.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/faulty", nil))
+	})
+
+	entries := s.Journal(Criteria{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "reset fault injection failed: connection can't be hijacked", entries[0].Error)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestServer_fault_resetTLS(t *testing.T) {
+	desc := descriptor(http.MethodGet, "/faulty", http.StatusOK, "01234567")
+	desc.Fault = &filesystem.Fault{Kind: filesystem.FaultReset}
+
+	s := newTestServer(t, desc)
+	ts := httptest.NewTLSServer(s.s.Handler)
+	defer ts.Close()
+
+	_, err := ts.Client().Get(ts.URL + "/faulty")
+	assert.ErrorIs(t, err, syscall.ECONNRESET)
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// maxEntryBody is how much of a request body a journal entry keeps, so a full journal stays small.
+const maxEntryBody = 64 << 10
+
+// Entry is a request received by the server.
+type Entry struct {
+	Time   time.Time   `json:"time"`
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+	// BodyTruncated tells the body was cut to its first 64 KiB.
+	BodyTruncated bool `json:"bodyTruncated,omitempty"`
+	// File is the response file of the matched route, empty if none matched.
+	File   string `json:"file,omitempty"`
+	Status int    `json:"status,omitempty"`
+	// Error tells why no complete response was sent, like the client canceling the request while it was delayed.
+	Error string `json:"error,omitempty"`
+
+	path string
+	// bodySum is the SHA-256 of the whole body, which criteria are compared against when the body was truncated.
+	bodySum []byte
+}
+
+// Criteria filters journal entries, empty fields match any entry.
+type Criteria struct {
+	Method string `json:"method"`
+	// Path is compared against the request URL path.
+	Path string `json:"path"`
+	// Body is compared semantically against JSON request bodies and verbatim against any other.
+	Body string `json:"body"`
+}
+
+func (c Criteria) matches(e Entry) bool {
+	if c.Method != "" && c.Method != e.Method {
+		return false
+	}
+
+	if c.Path != "" && c.Path != e.path {
+		return false
+	}
+
+	if c.Body == "" {
+		return true
+	}
+
+	if e.BodyTruncated {
+		sum := sha256.Sum256([]byte(c.Body))
+		return bytes.Equal(sum[:], e.bodySum)
+	}
+
+	return equalBodies(c.Body, e.Body)
+}
+
+func equalBodies(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	var ja, jb any
+	if json.Unmarshal([]byte(a), &ja) != nil || json.Unmarshal([]byte(b), &jb) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(ja, jb)
+}
+
+// journal keeps the last received requests, up to its size.
+type journal struct {
+	entries []Entry
+	size    int
+	mu      sync.Mutex
+}
+
+func (j *journal) add(e Entry) {
+	if j.size <= 0 {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.entries) == j.size {
+		copy(j.entries, j.entries[1:])
+		j.entries = j.entries[:len(j.entries)-1]
+	}
+
+	j.entries = append(j.entries, e)
+}
+
+func (j *journal) find(c Criteria) []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	out := make([]Entry, 0)
+	for _, e := range j.entries {
+		if c.matches(e) {
+			out = append(out, e)
+		}
+	}
+
+	return out
+}
+
+func (j *journal) clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entries = nil
+}
+
+func newEntry(c *call) Entry {
+	return Entry{
+		Time:   time.Now(),
+		Method: c.Method,
+		URL:    c.URL.String(),
+		Header: c.Header.Clone(),
+		path:   c.URL.Path,
+	}
+}
+
+// withBody returns the entry with the start of the body read so far.
+func (e Entry) withBody(c *capture) Entry {
+	e.Body, e.BodyTruncated, e.bodySum = string(c.head), c.size > maxEntryBody, c.sum.Sum(nil)
+	return e
+}
+
+// Journal returns the received requests matching the criteria, oldest first.
+func (s *Server) Journal(c Criteria) []Entry {
+	return s.journal.find(c)
+}
+
+// ClearJournal forgets every received request.
+func (s *Server) ClearJournal() {
+	s.journal.clear()
+}
+
+// Verify fails unless exactly times received requests match the criteria.
+func (s *Server) Verify(c Criteria, times int) error {
+	if got := len(s.journal.find(c)); got != times {
+		return fmt.Errorf("expected %d requests matching %+v, got %d", times, c, got)
+	}
+
+	return nil
+}
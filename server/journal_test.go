@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_journal_add(t *testing.T) {
+	j := &journal{size: 2}
+	for _, m := range []string{"GET", "POST", "PUT"} {
+		j.add(Entry{Method: m})
+	}
+
+	got := j.find(Criteria{})
+	require.Len(t, got, 2)
+	assert.Equal(t, "POST", got[0].Method)
+	assert.Equal(t, "PUT", got[1].Method)
+
+	j = &journal{}
+	j.add(Entry{Method: "GET"})
+	assert.Empty(t, j.find(Criteria{}))
+}
+
+func TestServer_journal_truncated(t *testing.T) {
+	s := newTestServer(t, descriptor(http.MethodPost, "/api/upload", http.StatusNoContent, ""))
+
+	do(t, s, http.MethodPost, "/api/upload", strings.Repeat("a", maxEntryBody))
+	do(t, s, http.MethodPost, "/api/upload", strings.Repeat("a", maxEntryBody+1))
+
+	got := s.Journal(Criteria{})
+	require.Len(t, got, 2)
+	assert.Len(t, got[0].Body, maxEntryBody)
+	assert.False(t, got[0].BodyTruncated)
+	assert.Len(t, got[1].Body, maxEntryBody)
+	assert.True(t, got[1].BodyTruncated)
+
+	assert.NoError(t, s.Verify(Criteria{Body: strings.Repeat("a", maxEntryBody+1)}, 1))
+	assert.NoError(t, s.Verify(Criteria{Body: strings.Repeat("a", maxEntryBody)}, 1))
+}
+
+func TestServer_Verify(t *testing.T) {
+	s := newTestServer(t, descriptor(http.MethodPatch, "/api/people/[id]", http.StatusNoContent, ""))
+
+	do(t, s, http.MethodPatch, "/api/people/1", `{"name": "John", "age": 3}`)
+	do(t, s, http.MethodPatch, "/api/people/1", `{"name": "Jane"}`)
+	do(t, s, http.MethodGet, "/api/people/1", "")
+
+	assert.NoError(t, s.Verify(Criteria{Method: http.MethodPatch, Path: "/api/people/1"}, 2))
+	assert.NoError(t, s.Verify(Criteria{Method: http.MethodPatch, Body: `{"age":3,"name":"John"}`}, 1))
+	assert.EqualError(t, s.Verify(Criteria{Method: http.MethodPatch, Path: "/api/people/2"}, 1),
+		"expected 1 requests matching {Method:PATCH Path:/api/people/2 Body:}, got 0")
+
+	entries := s.Journal(Criteria{Method: http.MethodPatch})
+	require.Len(t, entries, 2)
+	assert.Equal(t, "/api/people/1", entries[0].URL)
+	assert.Equal(t, "PATCH/api/people/[id]", entries[0].File)
+	assert.Equal(t, http.StatusNoContent, entries[0].Status)
+
+	status, _ := do(t, s, http.MethodPost, "/__sms/requests/verify", `{"method": "GET", "count": 1}`)
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, body := do(t, s, http.MethodPost, "/__sms/requests/verify", `{"method": "GET", "count": 2}`)
+	assert.Equal(t, http.StatusExpectationFailed, status)
+	assert.JSONEq(t, `{"error":"expected 2 requests matching {Method:GET Path: Body:}, got 1"}`, body)
+
+	status, _ = do(t, s, http.MethodDelete, "/__sms/requests", "")
+	assert.Equal(t, http.StatusNoContent, status)
+
+	_, body = do(t, s, http.MethodGet, "/__sms/requests", "")
+	assert.JSONEq(t, "[]", body)
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/agukrapo/simpler-mock-server/internal/jsonpath"
+)
+
+func matches(desc *filesystem.Descriptor, c *call) bool {
+	query := c.URL.Query()
+	for k, values := range desc.Query {
+		for _, v := range values {
+			if !slices.Contains(query[k], v) {
+				return false
+			}
+		}
+	}
+
+	for k, v := range desc.MatchHeaders {
+		if !matchHeader(c.Header.Values(k), v) {
+			return false
+		}
+	}
+
+	return matchBody(desc.MatchBody, c) && matchClient(desc.MatchClient, c)
+}
+
+func matchHeader(values []string, expected *string) bool {
+	switch {
+	case expected == nil:
+		return len(values) == 0
+	case *expected == "*":
+		return len(values) != 0
+	default:
+		return slices.Contains(values, *expected)
+	}
+}
+
+func matchBody(bm *filesystem.BodyMatcher, c *call) bool {
+	if bm == nil {
+		return true
+	}
+
+	if bm.Regex != nil && !bm.Regex.Match(c.body) {
+		return false
+	}
+
+	if bm.JSON != nil || len(bm.JSONPath) != 0 {
+		doc, ok := c.jsonBody()
+		if !ok {
+			return false
+		}
+
+		if bm.JSON != nil && !reflect.DeepEqual(bm.JSON, doc) {
+			return false
+		}
+
+		for path, expected := range bm.JSONPath {
+			if v, err := jsonpath.Get(doc, path); err != nil || !reflect.DeepEqual(expected, v) {
+				return false
+			}
+		}
+	}
+
+	form := c.formBody()
+	for k, v := range bm.Form {
+		if !slices.Contains(form[k], v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchClient(cm *filesystem.ClientMatcher, c *call) bool {
+	if cm == nil {
+		return true
+	}
+
+	cert, verified := c.clientCert()
+	if cert == nil {
+		return false
+	}
+
+	if cm.CN != "" && cm.CN != "*" && cm.CN != cert.Subject.CommonName {
+		return false
+	}
+
+	if cm.SAN != "" && !slices.Contains(subjectAltNames(cert), cm.SAN) {
+		return false
+	}
+
+	return cm.Verified == nil || *cm.Verified == verified
+}
+
+func subjectAltNames(cert *x509.Certificate) []string {
+	out := slices.Concat(cert.DNSNames, cert.EmailAddresses)
+	for _, ip := range cert.IPAddresses {
+		out = append(out, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		out = append(out, uri.String())
+	}
+
+	return out
+}
+
+// specificity returns how many request criteria the descriptor declares, variants declaring more criteria are tried first.
+func specificity(desc *filesystem.Descriptor) int {
+	var out int
+	for _, values := range desc.Query {
+		out += len(values)
+	}
+
+	out += len(desc.MatchHeaders) + desc.MatchBody.Len() + desc.MatchClient.Len()
+	if desc.Scenario != nil && desc.Scenario.Requires != "" {
+		out++
+	}
+
+	return out
+}
+
+func criteria(desc *filesystem.Descriptor) string {
+	var sb strings.Builder
+	sb.WriteString(desc.Query.Encode())
+
+	for _, k := range slices.Sorted(maps.Keys(desc.MatchHeaders)) {
+		if v := desc.MatchHeaders[k]; v != nil {
+			_, _ = fmt.Fprintf(&sb, "|%s:%s", http.CanonicalHeaderKey(k), *v)
+		} else {
+			_, _ = fmt.Fprintf(&sb, "|!%s", http.CanonicalHeaderKey(k))
+		}
+	}
+
+	if desc.MatchBody != nil {
+		b, _ := json.Marshal(desc.MatchBody)
+		_, _ = fmt.Fprintf(&sb, "|%s", b)
+	}
+
+	if desc.MatchClient != nil {
+		b, _ := json.Marshal(desc.MatchClient)
+		_, _ = fmt.Fprintf(&sb, "|client=%s", b)
+	}
+
+	if desc.Scenario != nil && desc.Scenario.Requires != "" {
+		_, _ = fmt.Fprintf(&sb, "|%s=%s", desc.Scenario.Name, desc.Scenario.Requires)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_matches(t *testing.T) {
+	tests := []struct {
+		name string
+		desc *filesystem.Descriptor
+		url  string
+		body string
+		want bool
+	}{
+		{"no criteria", &filesystem.Descriptor{}, "/search?q=foo", "", true},
+		{"query match", &filesystem.Descriptor{Query: url.Values{"q": {"foo"}}}, "/search?q=foo&page=1", "", true},
+		{"query mismatch", &filesystem.Descriptor{Query: url.Values{"q": {"foo"}}}, "/search?q=bar", "", false},
+		{"query missing", &filesystem.Descriptor{Query: url.Values{"q": {"foo"}}}, "/search", "", false},
+		{"query multiple values", &filesystem.Descriptor{Query: url.Values{"id": {"1", "2"}}}, "/search?id=2&id=1", "", true},
+		{"header match", &filesystem.Descriptor{MatchHeaders: map[string]*string{"x-tenant": ptr("acme")}}, "/search", "", true},
+		{"header mismatch", &filesystem.Descriptor{MatchHeaders: map[string]*string{"X-Tenant": ptr("other")}}, "/search", "", false},
+		{"header any value", &filesystem.Descriptor{MatchHeaders: map[string]*string{"X-Tenant": ptr("*")}}, "/search", "", true},
+		{"header absent", &filesystem.Descriptor{MatchHeaders: map[string]*string{"Authorization": nil}}, "/search", "", true},
+		{"header present", &filesystem.Descriptor{MatchHeaders: map[string]*string{"X-Tenant": nil}}, "/search", "", false},
+		{
+			"json match", &filesystem.Descriptor{MatchBody: &filesystem.BodyMatcher{JSON: map[string]any{"name": "invalid"}}},
+			"/people", `{ "name": "invalid" }`, true,
+		},
+		{
+			"json mismatch", &filesystem.Descriptor{MatchBody: &filesystem.BodyMatcher{JSON: map[string]any{"name": "invalid"}}},
+			"/people", `{"name":"invalid","age":3}`, false,
+		},
+		{
+			"json path match", &filesystem.Descriptor{MatchBody: &filesystem.BodyMatcher{JSONPath: map[string]any{"$.pets[0].age": float64(3)}}},
+			"/people", `{"name":"John","pets":[{"age":3}]}`, true,
+		},
+		{
+			"json path on invalid json", &filesystem.Descriptor{MatchBody: &filesystem.BodyMatcher{JSONPath: map[string]any{"$.name": "John"}}},
+			"/people", `name=John`, false,
+		},
+		{
+			"regex match", &filesystem.Descriptor{MatchBody: &filesystem.BodyMatcher{Regex: regexp.MustCompile(`"name":\s*"J`)}},
+			"/people", `{"name": "John"}`, true,
+		},
+		{
+			"regex mismatch", &filesystem.Descriptor{MatchBody: &filesystem.BodyMatcher{Regex: regexp.MustCompile(`^$`)}},
+			"/people", `{"name": "John"}`, false,
+		},
+		{
+			"form match", &filesystem.Descriptor{MatchBody: &filesystem.BodyMatcher{Form: map[string]string{"name": "John Doe"}}},
+			"/people", `name=John+Doe&age=3`, true,
+		},
+		{
+			"form mismatch", &filesystem.Descriptor{MatchBody: &filesystem.BodyMatcher{Form: map[string]string{"name": "Jane"}}},
+			"/people", `name=John`, false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.url, strings.NewReader(tt.body))
+			req.Header.Set("X-Tenant", "acme")
+
+			c := newCall(req)
+			require.NoError(t, c.readBody())
+
+			assert.Equal(t, tt.want, matches(tt.desc, c))
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func Test_matchClient(t *testing.T) {
+	ca, caKey := newCert(t, "CA", nil, nil)
+	partner, _ := newCert(t, "partner", ca, caKey)
+	stranger, _ := newCert(t, "stranger", nil, nil)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	verified, unverified := true, false
+
+	tests := []struct {
+		name string
+		cm   *filesystem.ClientMatcher
+		cert *x509.Certificate
+		want bool
+	}{
+		{"no criteria", nil, nil, true},
+		{"no certificate", &filesystem.ClientMatcher{CN: "*"}, nil, false},
+		{"any certificate", &filesystem.ClientMatcher{CN: "*"}, stranger, true},
+		{"cn match", &filesystem.ClientMatcher{CN: "partner"}, partner, true},
+		{"cn mismatch", &filesystem.ClientMatcher{CN: "partner"}, stranger, false},
+		{"san match", &filesystem.ClientMatcher{SAN: "partner.example.com"}, partner, true},
+		{"san mismatch", &filesystem.ClientMatcher{SAN: "partner.example.com"}, stranger, false},
+		{"verified", &filesystem.ClientMatcher{Verified: &verified}, partner, true},
+		{"verified mismatch", &filesystem.ClientMatcher{Verified: &verified}, stranger, false},
+		{"unverified", &filesystem.ClientMatcher{CN: "stranger", Verified: &unverified}, stranger, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/partners", nil)
+			if tt.cert != nil {
+				req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tt.cert}}
+			}
+
+			c := newCall(req)
+			c.clientCAs = pool
+
+			assert.Equal(t, tt.want, matches(&filesystem.Descriptor{MatchClient: tt.cm}, c))
+		})
+	}
+}
+
+// newCert creates a client certificate for the common name signed by the parent, self-signed if nil.
+func newCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		DNSNames:              []string{cn + ".example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, key
+}
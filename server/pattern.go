@@ -0,0 +1,122 @@
+package server
+
+import (
+	"strings"
+)
+
+type segmentKind uint8
+
+const (
+	literal segmentKind = iota
+	param
+	wildcard
+)
+
+type segment struct {
+	kind  segmentKind
+	value string
+}
+
+// pattern is a route containing `[name]` or `_name_` segments, that match any single path segment,
+// or a trailing `[...name]` segment, that matches the rest of the path.
+type pattern []segment
+
+func parsePattern(route string) (pattern, bool) {
+	chunks := splitPath(route)
+	out := make(pattern, 0, len(chunks))
+
+	var ok bool
+	for i, chunk := range chunks {
+		if name, found := wildcardName(chunk); found && i == len(chunks)-1 {
+			out = append(out, segment{kind: wildcard, value: name})
+			ok = true
+			continue
+		}
+
+		if name, found := paramName(chunk); found {
+			out = append(out, segment{kind: param, value: name})
+			ok = true
+			continue
+		}
+
+		out = append(out, segment{kind: literal, value: chunk})
+	}
+
+	return out, ok
+}
+
+func (p pattern) match(path string) (map[string]string, bool) {
+	chunks := splitPath(path)
+	out := make(map[string]string)
+
+	for i, seg := range p {
+		if i >= len(chunks) {
+			return nil, false
+		}
+
+		switch seg.kind {
+		case literal:
+			if seg.value != chunks[i] {
+				return nil, false
+			}
+		case param:
+			if chunks[i] == "" {
+				return nil, false
+			}
+			out[seg.value] = chunks[i]
+		case wildcard:
+			out[seg.value] = strings.Join(chunks[i:], "/")
+			return out, true
+		}
+	}
+
+	if len(chunks) != len(p) {
+		return nil, false
+	}
+
+	return out, true
+}
+
+// compare orders patterns from the most specific to the least specific one.
+func (p pattern) compare(other pattern) int {
+	for i := range min(len(p), len(other)) {
+		if p[i].kind != other[i].kind {
+			return int(p[i].kind) - int(other[i].kind)
+		}
+	}
+
+	return len(p) - len(other)
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func paramName(chunk string) (string, bool) {
+	if name, ok := enclosed(chunk, "[", "]"); ok {
+		return name, true
+	}
+
+	if name, ok := enclosed(chunk, "_", "_"); ok && !strings.HasPrefix(name, "_") && !strings.HasSuffix(name, "_") {
+		return name, true
+	}
+
+	return "", false
+}
+
+func wildcardName(chunk string) (string, bool) {
+	return enclosed(chunk, "[...", "]")
+}
+
+func enclosed(chunk, prefix, suffix string) (string, bool) {
+	if len(chunk) <= len(prefix)+len(suffix) || !strings.HasPrefix(chunk, prefix) || !strings.HasSuffix(chunk, suffix) {
+		return "", false
+	}
+
+	name := chunk[len(prefix) : len(chunk)-len(suffix)]
+	if strings.ContainsAny(name, "[]./") {
+		return "", false
+	}
+
+	return name, true
+}
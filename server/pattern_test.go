@@ -0,0 +1,89 @@
+package server
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parsePattern(t *testing.T) {
+	tests := []struct {
+		route  string
+		wantOk bool
+	}{
+		{"/api/people", false},
+		{"/api/people/a3b69b44-d562-11eb-b8bc-0242ac130003", false},
+		{"/api/people/[id]", true},
+		{"/api/people/_id_", true},
+		{"/api/[...rest]", true},
+		{"/api/[...rest]/people", false},
+		{"/api/__init__", false},
+		{"/api/[]", false},
+		{"/api/__", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			_, ok := parsePattern(tt.route)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+}
+
+func Test_pattern_match(t *testing.T) {
+	tests := []struct {
+		route      string
+		path       string
+		wantParams map[string]string
+		wantOk     bool
+	}{
+		{"/api/people/[id]", "/api/people/123", map[string]string{"id": "123"}, true},
+		{"/api/people/_id_", "/api/people/123", map[string]string{"id": "123"}, true},
+		{"/api/[kind]/[id]", "/api/people/123", map[string]string{"kind": "people", "id": "123"}, true},
+		{"/api/people/[id]", "/api/people", nil, false},
+		{"/api/people/[id]", "/api/people/123/pets", nil, false},
+		{"/api/people/[id]", "/api/pets/123", nil, false},
+		{"/api/[...rest]", "/api/people/123/pets", map[string]string{"rest": "people/123/pets"}, true},
+		{"/api/[...rest]", "/api/people", map[string]string{"rest": "people"}, true},
+		{"/api/[...rest]", "/api", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route+" "+tt.path, func(t *testing.T) {
+			p, _ := parsePattern(tt.route)
+			params, ok := p.match(tt.path)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantParams, params)
+		})
+	}
+}
+
+func Test_pattern_compare(t *testing.T) {
+	routes := []string{
+		"/api/[...rest]",
+		"/[kind]/people/[id]",
+		"/api/people/[id]/[...rest]",
+		"/api/[kind]/[id]",
+		"/api/people/[id]",
+	}
+
+	patterns := make([]pattern, len(routes))
+	for i, r := range routes {
+		patterns[i], _ = parsePattern(r)
+	}
+
+	slices.SortStableFunc(patterns, pattern.compare)
+
+	var got []pattern
+	for _, r := range []string{
+		"/api/people/[id]",
+		"/api/people/[id]/[...rest]",
+		"/api/[kind]/[id]",
+		"/api/[...rest]",
+		"/[kind]/people/[id]",
+	} {
+		p, _ := parsePattern(r)
+		got = append(got, p)
+	}
+
+	assert.Equal(t, got, patterns)
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+)
+
+const startedState = "Started"
+
+// scenarios maps scenario names to their current state.
+type scenarios map[string]string
+
+func (sc scenarios) state(name string) string {
+	if v, ok := sc[name]; ok {
+		return v
+	}
+
+	return startedState
+}
+
+func (sc scenarios) allows(desc *filesystem.Descriptor) bool {
+	if desc.Scenario == nil || desc.Scenario.Requires == "" {
+		return true
+	}
+
+	return sc.state(desc.Scenario.Name) == desc.Scenario.Requires
+}
+
+func (sc scenarios) transition(desc *filesystem.Descriptor) {
+	if desc.Scenario == nil || desc.Scenario.Next == "" {
+		return
+	}
+
+	sc[desc.Scenario.Name] = desc.Scenario.Next
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_scenarios(t *testing.T) {
+	empty := descriptor(http.MethodGet, "/cart", http.StatusOK, "[]")
+	empty.Scenario = &filesystem.Scenario{Name: "cart", Requires: "Started"}
+
+	full := descriptor(http.MethodGet, "/cart", http.StatusOK, `["item"]`)
+	full.Path = "GET/@full___cart.json"
+	full.Scenario = &filesystem.Scenario{Name: "cart", Requires: "has-items"}
+
+	add := descriptor(http.MethodPost, "/cart/items", http.StatusCreated, "")
+	add.Scenario = &filesystem.Scenario{Name: "cart", Next: "has-items"}
+
+	s := newTestServer(t, empty, full, add)
+
+	_, body := do(t, s, http.MethodGet, "/cart", "")
+	assert.Equal(t, "[]", body)
+
+	status, _ := do(t, s, http.MethodPost, "/cart/items", "")
+	assert.Equal(t, http.StatusCreated, status)
+
+	_, body = do(t, s, http.MethodGet, "/cart", "")
+	assert.Equal(t, `["item"]`, body)
+
+	status, _ = do(t, s, http.MethodPost, "/__sms/scenarios/reset", "")
+	assert.Equal(t, http.StatusNoContent, status)
+
+	_, body = do(t, s, http.MethodGet, "/cart", "")
+	assert.Equal(t, "[]", body)
+}
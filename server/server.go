@@ -2,22 +2,31 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/agukrapo/simpler-mock-server/filesystem"
 	"github.com/agukrapo/simpler-mock-server/internal/headers"
+	"github.com/agukrapo/simpler-mock-server/internal/latency"
 	"github.com/agukrapo/simpler-mock-server/internal/mime"
+	"github.com/agukrapo/simpler-mock-server/internal/throttle"
 	"github.com/rs/zerolog/log"
 )
 
 type fs interface {
 	Paths() ([]*filesystem.Descriptor, error)
 	Create(*http.Request) (*filesystem.Descriptor, error)
+	Record(req *http.Request, status int, typ mime.Type, body []byte) (*filesystem.Descriptor, error)
 	Notify() <-chan struct{}
 }
 
@@ -25,6 +34,11 @@ type route struct {
 	method, path string
 }
 
+type patternRoute struct {
+	route
+	pattern pattern
+}
+
 func descriptorToRoute(desc *filesystem.Descriptor) route {
 	return route{
 		method: desc.Method,
@@ -39,42 +53,163 @@ func requestToRoute(req *http.Request) route {
 	}
 }
 
-type dir map[mime.Type]*filesystem.Descriptor
+var (
+	errSequenceEnded = errors.New("sequence ended")
+	errNoMatch       = errors.New("no route matched")
+	errNotAcceptable = errors.New("no acceptable type")
+	errStopped       = errors.New("server stopped")
+	errCanceled      = errors.New("client canceled the request")
+)
+
+type Server struct {
+	s  *http.Server
+	fs fs
 
-func (d dir) resolveDescriptor(req *http.Request) (*filesystem.Descriptor, bool) {
-	if len(d) == 0 {
-		return nil, false
+	// ctx is the base context of every request, canceled with errStopped on Stop.
+	ctx  context.Context
+	stop context.CancelCauseFunc
+
+	sequenceEnd filesystem.SequenceEnd
+	adminPrefix string
+	journal     *journal
+	record      *upstream
+	proxy       http.Handler
+
+	readOnly      bool
+	notFoundDesc  *filesystem.Descriptor
+	notAcceptable bool
+	typeOrder     []mime.Type
+	latency       latency.Distribution
+	throttle      *throttle.Rate
+	tls           *tls.Config
+
+	routes    map[route]dir
+	patterns  []patternRoute
+	fallbacks []*filesystem.Descriptor
+	scenarios scenarios
+	mu        sync.RWMutex
+	// creating serialises route creation, so a new path gets a single file and a single upstream request.
+	creating sync.Mutex
+}
+
+type Option func(*Server)
+
+// WithSequenceEnd sets what sequences not declaring their own end do once all of their responses were returned.
+func WithSequenceEnd(end filesystem.SequenceEnd) Option {
+	return func(s *Server) {
+		s.sequenceEnd = end
 	}
+}
 
-	ct := headers.Accept(req)
-	if ct == "" {
-		for _, v := range d {
-			return v, true
+// WithAdminPrefix sets the path prefix reserved for the admin API, an empty prefix keeps the default one.
+func WithAdminPrefix(prefix string) Option {
+	return func(s *Server) {
+		if trimmed := strings.Trim(prefix, "/"); trimmed != "" {
+			s.adminPrefix = "/" + trimmed + "/"
 		}
 	}
+}
 
-	out, ok := d[ct]
-	return out, ok
+// WithJournalSize sets how many received requests are kept in the journal, 0 disables it.
+func WithJournalSize(size int) Option {
+	return func(s *Server) {
+		s.journal.size = size
+	}
 }
 
-type Server struct {
-	s  *http.Server
-	fs fs
+// WithRecordUpstream forwards requests not matching any route to the upstream server, saving its responses as new routes.
+func WithRecordUpstream(base *url.URL) Option {
+	return func(s *Server) {
+		if base != nil {
+			s.record = newUpstream(base)
+		}
+	}
+}
+
+// WithProxyUpstream proxies requests not matching any route to the upstream server.
+func WithProxyUpstream(base *url.URL) Option {
+	return func(s *Server) {
+		if base != nil {
+			s.proxy = newProxy(base)
+		}
+	}
+}
 
-	routes map[route]dir
-	mu     sync.RWMutex
+// WithReadOnly stops creating response files for requests not matching any route, responding not found instead.
+func WithReadOnly(readOnly bool) Option {
+	return func(s *Server) {
+		s.readOnly = readOnly
+	}
+}
+
+// WithNotFound sets the response returned when no route matches, instead of the plain text default one.
+func WithNotFound(desc *filesystem.Descriptor) Option {
+	return func(s *Server) {
+		s.notFoundDesc = desc
+	}
+}
+
+// WithNotAcceptable responds 406 Not Acceptable to requests matching a route whose types the request doesn't accept,
+// instead of creating a response file of the accepted type.
+func WithNotAcceptable(notAcceptable bool) Option {
+	return func(s *Server) {
+		s.notAcceptable = notAcceptable
+	}
 }
 
-func New(address string, fs fs) *Server {
+// WithTypeOrder sets the precedence of the types of a route when the request accepts several of them equally,
+// types not in the list follow in alphabetical order.
+func WithTypeOrder(types ...mime.Type) Option {
+	return func(s *Server) {
+		s.typeOrder = types
+	}
+}
+
+// WithLatency delays every response, on top of the delay of its file.
+func WithLatency(dist latency.Distribution) Option {
+	return func(s *Server) {
+		s.latency = dist
+	}
+}
+
+// WithThrottle limits the bandwidth of every response body, unless its file sets its own.
+func WithThrottle(rate *throttle.Rate) Option {
+	return func(s *Server) {
+		s.throttle = rate
+	}
+}
+
+// WithTLS serves HTTPS with the certificates of the config.
+func WithTLS(config *tls.Config) Option {
+	return func(s *Server) {
+		s.tls = config
+	}
+}
+
+func New(address string, fs fs, opts ...Option) *Server {
 	out := &Server{
-		fs:     fs,
-		routes: make(map[route]dir),
+		fs:          fs,
+		sequenceEnd: filesystem.RepeatLast,
+		adminPrefix: "/__sms/",
+		journal:     &journal{size: 1000},
+		routes:      make(map[route]dir),
+		scenarios:   make(scenarios),
 	}
 
+	for _, opt := range opts {
+		opt(out)
+	}
+
+	out.ctx, out.stop = context.WithCancelCause(context.Background())
+
 	out.s = &http.Server{
 		Addr:              address,
-		Handler:           http.HandlerFunc(out.handle),
+		Handler:           out.mux(),
 		ReadHeaderTimeout: 5 * time.Second,
+		TLSConfig:         out.tls,
+		BaseContext: func(net.Listener) context.Context {
+			return out.ctx
+		},
 	}
 
 	return out
@@ -87,7 +222,14 @@ func (s *Server) Start(ctx context.Context) error {
 
 	go s.watch(ctx)
 
-	if err := s.s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	serve := s.s.ListenAndServe
+	if s.s.TLSConfig != nil {
+		serve = func() error {
+			return s.s.ListenAndServeTLS("", "")
+		}
+	}
+
+	if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
@@ -95,6 +237,8 @@ func (s *Server) Start(ctx context.Context) error {
 }
 
 func (s *Server) Stop(ctx context.Context) {
+	s.stop(errStopped)
+
 	if err := s.s.Shutdown(ctx); err != nil {
 		log.Error().Err(err).Msg("Server shutdown failed")
 	}
@@ -124,7 +268,11 @@ func (s *Server) refresh() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	calls := sequenceCalls(s.routes)
+
 	clear(s.routes)
+	s.patterns = s.patterns[:0]
+	s.fallbacks = s.fallbacks[:0]
 
 	paths, err := s.fs.Paths()
 	if err != nil {
@@ -133,17 +281,26 @@ func (s *Server) refresh() error {
 
 	var count uint8
 	for _, desc := range paths {
+		if desc.Fallback {
+			s.fallbacks = append(s.fallbacks, desc)
+			log.Debug().Fields(fieldsFromDescriptor(desc)).Msg("Fallback added")
+			continue
+		}
+
 		r := descriptorToRoute(desc)
 		if _, ok := s.routes[r]; !ok {
 			s.routes[r] = make(dir)
+
+			if p, ok := parsePattern(r.path); ok {
+				s.patterns = append(s.patterns, patternRoute{route: r, pattern: p})
+			}
 		}
 
-		if _, ok := s.routes[r][desc.Type]; ok {
+		if !s.routes[r].add(desc, s.sequenceEnd) {
 			log.Warn().Fields(fieldsFromDescriptor(desc)).Msg("Route already exist")
 			continue
 		}
 
-		s.routes[r][desc.Type] = desc
 		log.Debug().Fields(fieldsFromDescriptor(desc)).Msg("Route added")
 
 		count++
@@ -153,63 +310,386 @@ func (s *Server) refresh() error {
 		log.Warn().Msg("No routes found")
 	}
 
+	restoreCalls(s.routes, calls)
+
+	slices.SortStableFunc(s.patterns, func(a, b patternRoute) int {
+		return a.pattern.compare(b.pattern)
+	})
+
+	slices.SortStableFunc(s.fallbacks, func(a, b *filesystem.Descriptor) int {
+		if n := len(b.Route) - len(a.Route); n != 0 {
+			return n
+		}
+
+		return len(b.Method) - len(a.Method)
+	})
+
 	return nil
 }
 
 func (s *Server) handle(writer http.ResponseWriter, req *http.Request) {
-	desc, err := s.resolveRoute(req)
+	c := newCall(req)
+	if s.tls != nil {
+		c.clientCAs = s.tls.ClientCAs
+	}
+
+	entry := newEntry(c)
+	defer func() {
+		s.journal.add(entry.withBody(c.captured))
+	}()
+
+	if s.needsBody(c) {
+		if err := c.readBody(); err != nil {
+			entry.Status, entry.Error = readFailed(writer, err), err.Error()
+			return
+		}
+	}
+
+	desc, err := s.resolveRoute(c)
+	if errors.Is(err, errNoMatch) && s.proxy != nil {
+		log.Debug().Str("request", fmt.Sprintf("%s %s", req.Method, req.URL)).Msg("Call proxied")
+		s.proxy.ServeHTTP(writer, req)
+		return
+	}
+
+	if err == nil && desc.Template {
+		if err := c.readBody(); err != nil {
+			entry.Status, entry.Error = readFailed(writer, err), err.Error()
+			return
+		}
+	}
+
+	if s.journal.size > 0 {
+		// the body may not be readable once responding, the journal entry keeps its start.
+		c.captured.drain()
+	}
+	if errors.Is(err, errNotAcceptable) {
+		log.Error().Err(err).Msg("Resolving route failed")
+		entry.Status = http.StatusNotAcceptable
+		s.fail(writer, c, http.StatusNotAcceptable)
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Resolving route failed")
-		http.NotFound(writer, req)
+		entry.Status = http.StatusNotFound
+		s.fail(writer, c, http.StatusNotFound)
 		return
 	}
 
-	time.Sleep(desc.Delay)
+	entry.File, entry.Status = desc.Path, desc.Status
+
+	if err := sleep(req.Context(), latency.Sum(s.latency, desc.Delay)); err != nil {
+		log.Warn().Err(err).Fields(fieldsFromDescriptor(desc)).Msg("Call canceled")
+		entry.Status, entry.Error = 0, err.Error()
+		if errors.Is(err, errStopped) {
+			// the client is still waiting, an empty 200 would pass for the delayed response.
+			entry.Status = http.StatusServiceUnavailable
+			plainError(writer, req, http.StatusServiceUnavailable)
+		}
+		return
+	}
 
 	reader, err := desc.Reader()
 	if err != nil {
 		log.Error().Err(err).Fields(fieldsFromDescriptor(desc)).Msg("Reading route failed")
-		http.NotFound(writer, req)
+		s.fail(writer, c, http.StatusNotFound)
+		return
+	}
+	defer reader.Close()
+
+	header := desc.Header
+	if desc.Template {
+		if reader, header, err = renderTemplate(desc, c, reader); err != nil {
+			log.Error().Err(err).Fields(fieldsFromDescriptor(desc)).Msg("Rendering route failed")
+			s.fail(writer, c, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if desc.Fault != nil {
+		if err := inject(writer, desc, header, reader); err != nil {
+			log.Error().Err(err).Fields(fieldsFromDescriptor(desc)).Msg("Fault injection failed")
+			entry.Error = desc.Fault.String() + " fault injection failed: " + err.Error()
+			if errors.Is(err, errNotHijackable) {
+				// HTTP/2 connections can't be hijacked, aborting the stream still breaks the response.
+				panic(http.ErrAbortHandler)
+			}
+			return
+		}
+		entry.Error = desc.Fault.String() + " fault injected"
+		return
+	}
+
+	if events, streamed, err := split(desc, reader); streamed {
+		if err != nil {
+			log.Error().Err(err).Fields(fieldsFromDescriptor(desc)).Msg("Splitting events failed")
+			s.fail(writer, c, http.StatusInternalServerError)
+			return
+		}
+
+		if err := stream(req.Context(), writer, desc, header, events); err != nil {
+			log.Warn().Err(err).Fields(fieldsFromDescriptor(desc)).Msg("Streaming events stopped")
+			entry.Error = err.Error()
+		}
+		return
+	}
+
+	send(s.throttled(req.Context(), writer, desc), desc, header, reader)
+
+	log.Debug().Str("request", fmt.Sprintf("%s %s", req.Method, req.URL)).Str("status", fmt.Sprintf("%d %s", desc.Status, http.StatusText(desc.Status))).Msg("Call received")
+}
+
+// fail responds with the status, using the closest fallback file for the request if any.
+func (s *Server) fail(writer http.ResponseWriter, c *call, status int) {
+	desc := s.fallback(c, status)
+	if desc == nil && status == http.StatusNotFound {
+		desc = s.notFoundDesc
+	}
+
+	if desc == nil {
+		plainError(writer, c.Request, status)
+		return
+	}
+
+	reader, err := desc.Reader()
+	if err != nil {
+		log.Error().Err(err).Fields(fieldsFromDescriptor(desc)).Msg("Reading fallback route failed")
+		plainError(writer, c.Request, status)
 		return
 	}
 	defer reader.Close()
 
+	send(writer, desc, desc.Header, reader)
+}
+
+func (s *Server) fallback(c *call, status int) *filesystem.Descriptor {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// the candidates are the types of the most specific fallback file applying to the call.
+	var candidates []*filesystem.Descriptor
+	for _, desc := range s.fallbacks {
+		if desc.Status != status || desc.Method != "" && desc.Method != c.Method || !strings.HasPrefix(c.URL.Path, desc.Route) {
+			continue
+		}
+
+		if len(candidates) == 0 || len(desc.Route) == len(candidates[0].Route) && desc.Method == candidates[0].Method {
+			candidates = append(candidates, desc)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	types := make([]mime.Type, len(candidates))
+	for i, desc := range candidates {
+		types[i] = desc.Type
+	}
+
+	// the first candidate is used even if the call accepts none of them, an error response is better than none.
+	ranked := headers.Rank(c.Request, types)
+	if len(ranked) == 0 {
+		return candidates[0]
+	}
+
+	for _, desc := range candidates {
+		if desc.Type == ranked[0] {
+			return desc
+		}
+	}
+
+	return candidates[0]
+}
+
+func plainError(writer http.ResponseWriter, req *http.Request, status int) {
+	if status == http.StatusNotFound {
+		http.NotFound(writer, req)
+		return
+	}
+
+	http.Error(writer, http.StatusText(status), status)
+}
+
+func send(writer http.ResponseWriter, desc *filesystem.Descriptor, header http.Header, reader io.Reader) {
 	writer.Header().Set("Content-Type", string(desc.Type))
+	for k, v := range header {
+		writer.Header()[k] = v
+	}
 	writer.WriteHeader(desc.Status)
 
 	if _, err := io.Copy(writer, reader); err != nil {
-		log.Error().Fields(fieldsFromDescriptor(desc)).Msg("File copy failed")
-		http.NotFound(writer, req)
-		return
+		log.Error().Err(err).Fields(fieldsFromDescriptor(desc)).Msg("File copy failed")
 	}
+}
 
-	log.Debug().Str("request", fmt.Sprintf("%s %s", req.Method, req.URL)).Str("status", fmt.Sprintf("%d %s", desc.Status, http.StatusText(desc.Status))).Msg("Call received")
+// sleep waits for the duration, returning the cause of the context cancellation if it happens first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		if err := context.Cause(ctx); !errors.Is(err, context.Canceled) {
+			return err
+		}
+		return errCanceled
+	case <-timer.C:
+		return nil
+	}
 }
 
-func (s *Server) resolveRoute(req *http.Request) (*filesystem.Descriptor, error) {
-	r := requestToRoute(req)
-	desc, ok := s.routes[r].resolveDescriptor(req)
-	if !ok {
-		s.mu.Lock()
-		defer s.mu.Unlock()
+func (s *Server) resolveRoute(c *call) (*filesystem.Descriptor, error) {
+	if desc, ok, err := s.match(c); ok {
+		return desc, err
+	}
 
-		var err error
-		desc, err = s.fs.Create(req.Clone(context.Background()))
-		if err != nil {
+	if s.proxy != nil || s.readOnly {
+		return nil, errNoMatch
+	}
+
+	s.creating.Lock()
+	defer s.creating.Unlock()
+
+	// a concurrent request may have created the route while this one waited.
+	if desc, ok, err := s.match(c); ok {
+		return desc, err
+	}
+
+	desc, err := s.create(c)
+	if errors.Is(err, os.ErrExist) {
+		// the file was created outside the server and isn't a route yet.
+		if err := s.refresh(); err != nil {
 			return nil, err
 		}
 
-		if s.routes[r] == nil {
-			s.routes[r] = make(dir)
+		if desc, ok, err := s.match(c); ok {
+			return desc, err
 		}
 
-		s.routes[r][desc.Type] = desc
-		log.Debug().Fields(fieldsFromDescriptor(desc)).Msg("Route created")
+		// the route has a file of the type the call prefers, yet the call accepts none of its types.
+		return nil, errNotAcceptable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := requestToRoute(c.Request)
+	if s.routes[r] == nil {
+		s.routes[r] = make(dir)
 	}
 
+	s.routes[r].add(desc, s.sequenceEnd)
+	log.Debug().Fields(fieldsFromDescriptor(desc)).Msg("Route created")
+
 	return desc, nil
 }
 
+// needsBody tells whether any route the call may match has body criteria, which need the body read into memory.
+func (s *Server) needsBody(c *call) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.routes[requestToRoute(c.Request)].hasBodyCriteria() {
+		return true
+	}
+
+	for _, pr := range s.patterns {
+		if pr.method != c.Method {
+			continue
+		}
+
+		if _, ok := pr.pattern.match(c.URL.Path); ok && s.routes[pr.route].hasBodyCriteria() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) match(c *call) (*filesystem.Descriptor, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, notAcceptable, found := s.lookup(c)
+	if v == nil {
+		// a file of the type the call prefers is created otherwise, which needs a concrete type.
+		if notAcceptable && (s.notAcceptable || headers.Accept(c.Request) == "") {
+			return nil, true, errNotAcceptable
+		}
+
+		// the route exists but none of its variants matched, creating a file for it would overwrite an existing one,
+		// unlike recording one bound to the call query.
+		if found && !notAcceptable && (s.record == nil || c.URL.RawQuery == "") {
+			return nil, true, errNoMatch
+		}
+
+		return nil, false, nil
+	}
+
+	desc := v.next()
+	if desc == nil {
+		return nil, true, errSequenceEnded
+	}
+
+	s.scenarios.transition(desc)
+	return desc, true, nil
+}
+
+func (s *Server) create(c *call) (*filesystem.Descriptor, error) {
+	if s.record == nil {
+		return s.fs.Create(c.Clone(context.Background()))
+	}
+
+	res, body, err := s.record.forward(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.fs.Record(c.Clone(context.Background()), res.StatusCode, contentType(res), body)
+}
+
+// lookup returns the variant matching the call, whether the call accepts none of the types of the matching variants
+// and whether any route matched its path.
+func (s *Server) lookup(c *call) (*variant, bool, bool) {
+	d := s.routes[requestToRoute(c.Request)]
+	found := len(d) != 0
+
+	v, notAcceptable := d.resolveVariant(c, s.scenarios, s.typeOrder)
+	if v != nil {
+		return v, false, true
+	}
+
+	for _, pr := range s.patterns {
+		if pr.method != c.Method {
+			continue
+		}
+
+		params, ok := pr.pattern.match(c.URL.Path)
+		if !ok {
+			continue
+		}
+		found = true
+
+		v, na := s.routes[pr.route].resolveVariant(c, s.scenarios, s.typeOrder)
+		if v != nil {
+			c.params = params
+			return v, false, true
+		}
+
+		notAcceptable = notAcceptable || na
+	}
+
+	return nil, notAcceptable, found
+}
+
 func fieldsFromDescriptor(desc *filesystem.Descriptor) map[string]interface{} {
 	out := map[string]interface{}{
 		"method": desc.Method,
@@ -219,8 +699,44 @@ func fieldsFromDescriptor(desc *filesystem.Descriptor) map[string]interface{} {
 		"type":   desc.Type,
 	}
 
-	if desc.Delay != 0 {
-		out["delay"] = desc.Delay
+	if desc.Delay != nil {
+		out["delay"] = desc.Delay.String()
+	}
+
+	if desc.Template {
+		out["template"] = true
+	}
+
+	if desc.Fault != nil {
+		out["fault"] = desc.Fault.String()
+	}
+
+	if desc.Throttle != nil {
+		out["throttle"] = desc.Throttle.String()
+	}
+
+	if desc.Loop {
+		out["loop"] = true
+	}
+
+	if desc.Stream {
+		out["stream"] = desc.StreamInterval.String()
+	}
+
+	if desc.Default {
+		out["default"] = true
+	}
+
+	if desc.Sequence != 0 {
+		out["sequence"] = desc.Sequence
+	}
+
+	if desc.Scenario != nil {
+		out["scenario"] = desc.Scenario.Name
+	}
+
+	if c := criteria(desc); c != "" {
+		out["criteria"] = c
 	}
 
 	return out
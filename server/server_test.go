@@ -0,0 +1,505 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/agukrapo/simpler-mock-server/internal/latency"
+	"github.com/agukrapo/simpler-mock-server/internal/mime"
+	"github.com/agukrapo/simpler-mock-server/internal/throttle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFS struct {
+	descs   []*filesystem.Descriptor
+	created []string
+	mu      sync.Mutex
+}
+
+func (f *fakeFS) Paths() ([]*filesystem.Descriptor, error) {
+	return f.descs, nil
+}
+
+func (f *fakeFS) Create(req *http.Request) (*filesystem.Descriptor, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file := req.Method + " " + req.URL.Path
+	exists := slices.ContainsFunc(f.descs, func(desc *filesystem.Descriptor) bool {
+		return desc.Method == req.Method && desc.Route == req.URL.Path && desc.Type == "application/json"
+	})
+	if exists || slices.Contains(f.created, file) {
+		return nil, fmt.Errorf("os.OpenFile: %w", os.ErrExist)
+	}
+
+	f.created = append(f.created, file)
+	return descriptor(req.Method, req.URL.Path, http.StatusOK, ""), nil
+}
+
+func (f *fakeFS) Record(req *http.Request, status int, typ mime.Type, body []byte) (*filesystem.Descriptor, error) {
+	desc := descriptor(req.Method, req.URL.Path, status, string(body))
+	desc.Type = typ
+	if len(req.URL.Query()) != 0 {
+		desc.Query = req.URL.Query()
+	}
+	return desc, nil
+}
+
+func (f *fakeFS) Notify() <-chan struct{} {
+	return nil
+}
+
+func descriptor(method, route string, status int, body string) *filesystem.Descriptor {
+	return &filesystem.Descriptor{
+		Method: method,
+		Path:   method + route,
+		Route:  route,
+		Status: status,
+		Type:   "application/json",
+		Reader: func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(body)), nil
+		},
+	}
+}
+
+func newTestServer(t *testing.T, descs ...*filesystem.Descriptor) *Server {
+	t.Helper()
+
+	s := New("", &fakeFS{descs: descs})
+	require.NoError(t, s.refresh())
+
+	return s
+}
+
+func do(t *testing.T, s *Server, method, target, body string) (int, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	s.s.Handler.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+	return rec.Code, rec.Body.String()
+}
+
+func TestServer_handle(t *testing.T) {
+	exact := descriptor(http.MethodGet, "/api/people/1", http.StatusOK, "exact")
+	param := descriptor(http.MethodGet, "/api/people/[id]", http.StatusOK, "param")
+	rest := descriptor(http.MethodGet, "/api/[...rest]", http.StatusOK, "rest")
+	paged := descriptor(http.MethodGet, "/api/people", http.StatusOK, "paged")
+	paged.Path = "GET/api/page=2___people.json"
+	paged.Query = url.Values{"page": {"2"}}
+	people := descriptor(http.MethodGet, "/api/people", http.StatusOK, "people")
+
+	s := newTestServer(t, exact, param, rest, paged, people)
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/api/people/1", "exact"},
+		{"/api/people/2", "param"},
+		{"/api/pets/2", "rest"},
+		{"/api/people?page=2", "paged"},
+		{"/api/people?page=3", "people"},
+		{"/other", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			status, body := do(t, s, http.MethodGet, tt.target, "")
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, tt.want, body)
+		})
+	}
+}
+
+func TestServer_handle_unmatchedCriteria(t *testing.T) {
+	tenant := "acme"
+	partners := descriptor(http.MethodGet, "/api/partners", http.StatusOK, "partners")
+	partners.MatchHeaders = map[string]*string{"X-Tenant": &tenant}
+	person := descriptor(http.MethodGet, "/api/people/[id]", http.StatusOK, "person")
+	person.MatchHeaders = map[string]*string{"X-Tenant": &tenant}
+
+	fs := &fakeFS{descs: []*filesystem.Descriptor{partners, person}}
+	s := New("", fs)
+	require.NoError(t, s.refresh())
+
+	for _, target := range []string{"/api/partners", "/api/people/1"} {
+		status, _ := do(t, s, http.MethodGet, target, "")
+		assert.Equal(t, http.StatusNotFound, status)
+	}
+	assert.Empty(t, fs.created)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/partners", nil)
+	req.Header.Set("X-Tenant", "acme")
+	rec := httptest.NewRecorder()
+	s.s.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, "partners", rec.Body.String())
+}
+
+func TestServer_handle_concurrentCreate(t *testing.T) {
+	fs := &fakeFS{}
+	s := New("", fs)
+	require.NoError(t, s.refresh())
+
+	var wg sync.WaitGroup
+	statuses := make([]int, 8)
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i], _ = do(t, s, http.MethodGet, "/api/new", "")
+		}()
+	}
+	wg.Wait()
+
+	for _, status := range statuses {
+		assert.Equal(t, http.StatusOK, status)
+	}
+	assert.Equal(t, []string{"GET /api/new"}, fs.created)
+}
+
+func TestServer_handle_largeBody(t *testing.T) {
+	upload := descriptor(http.MethodPut, "/api/upload", http.StatusNoContent, "")
+	search := descriptor(http.MethodPost, "/api/search", http.StatusOK, "found")
+	search.MatchBody = &filesystem.BodyMatcher{Regex: regexp.MustCompile("a+")}
+	s := newTestServer(t, upload, search)
+
+	body := strings.Repeat("a", maxBodySize+1)
+
+	status, _ := do(t, s, http.MethodPut, "/api/upload", body)
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, _ = do(t, s, http.MethodPost, "/api/search", body)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+
+	status, got := do(t, s, http.MethodPost, "/api/search", "aaa")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "found", got)
+
+	entries := s.Journal(Criteria{Method: http.MethodPut})
+	require.Len(t, entries, 1)
+	assert.Len(t, entries[0].Body, maxEntryBody)
+	assert.True(t, entries[0].BodyTruncated)
+}
+
+func TestServer_refresh_keepsSequences(t *testing.T) {
+	first := descriptor(http.MethodGet, "/job", http.StatusAccepted, "pending")
+	first.Sequence = 1
+	second := descriptor(http.MethodGet, "/job", http.StatusOK, "done")
+	second.Sequence = 2
+	s := newTestServer(t, first, second)
+
+	status, body := do(t, s, http.MethodGet, "/job", "")
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.Equal(t, "pending", body)
+
+	require.NoError(t, s.refresh())
+
+	status, body = do(t, s, http.MethodGet, "/job", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "done", body)
+}
+
+func TestServer_readOnly(t *testing.T) {
+	s := newTestServer(t)
+	WithReadOnly(true)(s)
+
+	status, body := do(t, s, http.MethodGet, "/wp-admin", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "404 page not found\n", body)
+	assert.Empty(t, s.Routes())
+
+	WithNotFound(descriptor("", "", http.StatusNotFound, `{"error":"not found"}`))(s)
+
+	status, body = do(t, s, http.MethodGet, "/wp-admin", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.JSONEq(t, `{"error":"not found"}`, body)
+	assert.Empty(t, s.Routes())
+}
+
+func TestServer_fallback(t *testing.T) {
+	fallback := func(method, route string, status int, typ mime.Type, body string) *filesystem.Descriptor {
+		desc := descriptor(method, route, status, body)
+		desc.Type = typ
+		desc.Fallback = true
+		return desc
+	}
+
+	s := newTestServer(t,
+		fallback("", "/", http.StatusNotFound, "application/json", "root"),
+		fallback("", "/", http.StatusNotFound, "application/xml", "root xml"),
+		fallback(http.MethodGet, "/", http.StatusNotFound, "application/json", "get"),
+		fallback(http.MethodGet, "/api/", http.StatusNotFound, "application/json", "get api"),
+		fallback("", "/", http.StatusInternalServerError, "application/json", "error"),
+	)
+	WithReadOnly(true)(s)
+
+	tests := []struct {
+		method, target, accept string
+		want                   string
+	}{
+		{http.MethodGet, "/api/people", "", "get api"},
+		{http.MethodGet, "/other", "", "get"},
+		{http.MethodPost, "/api/people", "", "root"},
+		{http.MethodPost, "/api/people", "application/xml", "root xml"},
+		{http.MethodPost, "/api/people", "application/*", "root"},
+		{http.MethodPost, "/api/people", "application/json;q=0.5, application/xml", "root xml"},
+		{http.MethodPost, "/api/people", "application/json;q=0, application/*", "root xml"},
+		{http.MethodPost, "/api/people", "text/csv", "root"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target+" "+tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.Header.Set("Accept", tt.accept)
+
+			rec := httptest.NewRecorder()
+			s.s.Handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Equal(t, tt.want, rec.Body.String())
+		})
+	}
+}
+
+func TestServer_negotiation(t *testing.T) {
+	typed := func(typ mime.Type, body string) *filesystem.Descriptor {
+		desc := descriptor(http.MethodGet, "/api/people", http.StatusOK, body)
+		desc.Type = typ
+		return desc
+	}
+
+	s := newTestServer(t,
+		typed("application/json", "json"),
+		typed("application/xml", "xml"),
+		typed("text/html", "html"),
+	)
+	WithNotAcceptable(true)(s)
+
+	tests := []struct {
+		accept     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"", http.StatusOK, "json"},
+		{"*/*", http.StatusOK, "json"},
+		{"application/xml", http.StatusOK, "xml"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", http.StatusOK, "html"},
+		{"application/xml;q=0.5, application/*;q=0.9", http.StatusOK, "json"},
+		{"application/json;q=0, application/*", http.StatusOK, "xml"},
+		{"text/*", http.StatusOK, "html"},
+		{"text/csv", http.StatusNotAcceptable, "Not Acceptable\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/people", nil)
+			req.Header.Set("Accept", tt.accept)
+
+			rec := httptest.NewRecorder()
+			s.s.Handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestServer_negotiation_noConcreteType(t *testing.T) {
+	fs := &fakeFS{descs: []*filesystem.Descriptor{descriptor(http.MethodGet, "/api/people", http.StatusOK, "json")}}
+	s := New("", fs)
+	require.NoError(t, s.refresh())
+
+	for _, accept := range []string{"text/*", "application/json;q=0", "application/x-unknown"} {
+		t.Run(accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/people", nil)
+			req.Header.Set("Accept", accept)
+
+			rec := httptest.NewRecorder()
+			s.s.Handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+		})
+	}
+	assert.Empty(t, fs.created)
+}
+
+func TestServer_negotiation_default(t *testing.T) {
+	typed := func(typ mime.Type, body string) *filesystem.Descriptor {
+		desc := descriptor(http.MethodGet, "/api/people", http.StatusOK, body)
+		desc.Type = typ
+		return desc
+	}
+
+	xml := typed("application/xml", "xml")
+	xml.Default = true
+
+	s := newTestServer(t, typed("application/json", "json"), xml, typed("text/html", "html"))
+	WithTypeOrder("text/html")(s)
+
+	tests := []struct {
+		accept   string
+		wantBody string
+	}{
+		{"", "xml"},
+		{"*/*", "xml"},
+		{"application/json, text/html", "html"},
+		{"application/json, text/html;q=0.9", "json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/people", nil)
+			req.Header.Set("Accept", tt.accept)
+
+			rec := httptest.NewRecorder()
+			s.s.Handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestServer_handle_canceled(t *testing.T) {
+	slow := descriptor(http.MethodGet, "/slow", http.StatusOK, "slow")
+	slow.Delay = latency.Fixed(time.Minute)
+
+	tests := []struct {
+		name       string
+		cancel     func(s *Server, cancel context.CancelFunc)
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name: "client",
+			cancel: func(_ *Server, cancel context.CancelFunc) {
+				cancel()
+			},
+			wantStatus: 0,
+			wantError:  "client canceled the request",
+		},
+		{
+			name: "server",
+			cancel: func(s *Server, _ context.CancelFunc) {
+				s.Stop(context.Background())
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantError:  "server stopped",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, slow)
+
+			ctx, cancel := context.WithCancel(s.s.BaseContext(nil))
+			defer cancel()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				s.s.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequestWithContext(ctx, http.MethodGet, "/slow", nil))
+			}()
+
+			time.Sleep(10 * time.Millisecond)
+			tt.cancel(s, cancel)
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("delay not interrupted")
+			}
+
+			entries := s.Journal(Criteria{})
+			require.Len(t, entries, 1)
+			assert.Equal(t, "GET/slow", entries[0].File)
+			assert.Equal(t, tt.wantStatus, entries[0].Status)
+			assert.Equal(t, tt.wantError, entries[0].Error)
+		})
+	}
+}
+
+func TestServer_Stop_delayed(t *testing.T) {
+	slow := descriptor(http.MethodGet, "/slow", http.StatusInternalServerError, "slow")
+	slow.Delay = latency.Fixed(5 * time.Second)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	s := New(address, &fakeFS{descs: []*filesystem.Descriptor{slow}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		assert.NoError(t, s.Start(ctx))
+	}()
+
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			return false
+		}
+		return conn.Close() == nil
+	}, time.Second, 10*time.Millisecond)
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		s.Stop(context.Background())
+	}()
+
+	res, err := http.Get("http://" + address + "/slow")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	assert.Equal(t, "Service Unavailable\n", string(body))
+}
+
+func TestServer_handle_throttled(t *testing.T) {
+	rate := func(s string) *throttle.Rate {
+		r, err := throttle.Parse(s)
+		require.NoError(t, err)
+		return r
+	}
+
+	own := descriptor(http.MethodGet, "/own", http.StatusOK, "0123456789")
+	own.Throttle = rate("chunk-5B-50ms")
+	global := descriptor(http.MethodGet, "/global", http.StatusOK, "0123456789")
+
+	s := newTestServer(t, own, global)
+	WithThrottle(rate("chunk-2B-50ms"))(s)
+
+	tests := []struct {
+		target  string
+		minTime time.Duration
+	}{
+		{"/own", 50 * time.Millisecond},
+		{"/global", 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			start := time.Now()
+
+			status, body := do(t, s, http.MethodGet, tt.target, "")
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, "0123456789", body)
+			assert.GreaterOrEqual(t, time.Since(start), tt.minTime)
+		})
+	}
+}
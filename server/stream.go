@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+)
+
+// split reads the events of the descriptors streamed one at a time, Server-Sent Events or the lines of a `stream` prefixed file.
+func split(desc *filesystem.Descriptor, reader io.Reader) ([]filesystem.Event, bool, error) {
+	switch {
+	case desc.Type == filesystem.EventStream:
+		events, err := filesystem.ParseEvents(reader)
+		return events, true, err
+	case desc.Stream:
+		events, err := filesystem.SplitLines(reader, desc.StreamInterval)
+		return events, true, err
+	default:
+		return nil, false, nil
+	}
+}
+
+// stream sends the events one at a time, each one after its delay, over and over if the descriptor loops.
+func stream(ctx context.Context, writer http.ResponseWriter, desc *filesystem.Descriptor, header http.Header, events []filesystem.Event) error {
+	writer.Header().Set("Content-Type", string(desc.Type))
+	writer.Header().Set("Cache-Control", "no-cache")
+	for k, v := range header {
+		writer.Header()[k] = v
+	}
+	writer.WriteHeader(desc.Status)
+
+	rc := http.NewResponseController(writer)
+	if err := rc.Flush(); err != nil {
+		return err
+	}
+
+	for {
+		for _, event := range events {
+			if err := sleep(ctx, event.Delay); err != nil {
+				return err
+			}
+
+			if _, err := writer.Write(event.Data); err != nil {
+				return err
+			}
+
+			if err := rc.Flush(); err != nil {
+				return err
+			}
+		}
+
+		if !desc.Loop || len(events) == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_stream(t *testing.T) {
+	events := descriptor(http.MethodGet, "/events", http.StatusOK, "data: 1\n\ndelay: 50ms\ndata: 2\n\n")
+	events.Type = filesystem.EventStream
+	looped := descriptor(http.MethodGet, "/looped", http.StatusOK, "delay: 10ms\ndata: x\n\n")
+	looped.Type = filesystem.EventStream
+	looped.Loop = true
+
+	lines := descriptor(http.MethodGet, "/lines", http.StatusOK, "{\"n\":1}\n{\"n\":2}\n")
+	lines.Type = "application/x-ndjson"
+	lines.Stream, lines.StreamInterval = true, 50*time.Millisecond
+
+	s := newTestServer(t, events, looped, lines)
+	ts := httptest.NewServer(s.s.Handler)
+	defer ts.Close()
+
+	t.Run("events", func(t *testing.T) {
+		start := time.Now()
+
+		res, err := ts.Client().Get(ts.URL + "/events")
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+		assert.Equal(t, "no-cache", res.Header.Get("Cache-Control"))
+
+		lines := readLines(t, bufio.NewReader(res.Body), 4)
+		assert.Equal(t, []string{"data: 1\n", "\n", "data: 2\n", "\n"}, lines)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("lines", func(t *testing.T) {
+		start := time.Now()
+
+		res, err := ts.Client().Get(ts.URL + "/lines")
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		assert.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+		assert.Equal(t, []string{"chunked"}, res.TransferEncoding)
+
+		lines := readLines(t, bufio.NewReader(res.Body), 2)
+		assert.Equal(t, []string{"{\"n\":1}\n", "{\"n\":2}\n"}, lines)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("looped", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/looped", nil)
+		require.NoError(t, err)
+
+		res, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		lines := readLines(t, bufio.NewReader(res.Body), 6)
+		assert.Equal(t, []string{"data: x\n", "\n", "data: x\n", "\n", "data: x\n", "\n"}, lines)
+	})
+}
+
+func readLines(t *testing.T, r *bufio.Reader, n int) []string {
+	t.Helper()
+
+	out := make([]string, n)
+	for i := range out {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		out[i] = line
+	}
+
+	return out
+}
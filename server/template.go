@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/google/uuid"
+)
+
+const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+var templateFuncs = template.FuncMap{
+	"now":  time.Now,
+	"uuid": uuid.NewString,
+	"randInt": func(from, to int) int {
+		return from + rand.IntN(to-from+1) // #nosec G404 -- not security sensitive
+	},
+	"randString": func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = letters[rand.IntN(len(letters))] // #nosec G404 -- not security sensitive
+		}
+		return string(b)
+	},
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+type templateData struct {
+	Method  string
+	Path    string
+	Params  map[string]string
+	Query   url.Values
+	Header  http.Header
+	Body    any
+	RawBody string
+}
+
+func newTemplateData(c *call) *templateData {
+	body, _ := c.jsonBody()
+
+	return &templateData{
+		Method:  c.Method,
+		Path:    c.URL.Path,
+		Params:  c.params,
+		Query:   c.URL.Query(),
+		Header:  c.Header,
+		Body:    body,
+		RawBody: string(c.body),
+	}
+}
+
+func render(name, text string, data *templateData) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("template.Parse: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("template.Execute: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func renderTemplate(desc *filesystem.Descriptor, c *call, reader io.Reader) (io.ReadCloser, http.Header, error) {
+	text, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	data := newTemplateData(c)
+
+	body, err := render(desc.Path, string(text), data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := make(http.Header, len(desc.Header))
+	for k, values := range desc.Header {
+		for _, v := range values {
+			out, err := render(k, v, data)
+			if err != nil {
+				return nil, nil, err
+			}
+			header.Add(k, string(out))
+		}
+	}
+
+	return io.NopCloser(bytes.NewReader(body)), header, nil
+}
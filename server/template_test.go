@@ -0,0 +1,63 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_renderTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string
+	}{
+		{"plain", `{"ok":true}`, `{"ok":true}`, ""},
+		{"path param", `{"id":"{{ .Params.id }}"}`, `{"id":"123"}`, ""},
+		{"query", `{{ .Query.Get "q" }}`, "foo", ""},
+		{"header", `{{ .Header.Get "X-Tenant" }}`, "acme", ""},
+		{"json body", `{{ .Body.name }} {{ index .Body.pets 0 }}`, "John Rex", ""},
+		{"json func", `{{ json .Body.pets }}`, `["Rex"]`, ""},
+		{"method and path", `{{ .Method }} {{ .Path }}`, "POST /api/people/123", ""},
+		{"random int", `{{ randInt 7 7 }}`, "7", ""},
+		{"random string", `{{ len (randString 12) }}`, "12", ""},
+		{"uuid", `{{ len uuid }}`, "36", ""},
+		{"now", `{{ gt now.Year 2000 }}`, "true", ""},
+		{"invalid", `{{ .Nope`, "", "template.Parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/people/123?q=foo", strings.NewReader(`{"name":"John","pets":["Rex"]}`))
+			req.Header.Set("X-Tenant", "acme")
+
+			c := newCall(req)
+			require.NoError(t, c.readBody())
+			c.params = map[string]string{"id": "123"}
+
+			desc := &filesystem.Descriptor{
+				Path:   "people.json.tmpl",
+				Header: http.Header{"Location": {"/api/people/{{ .Params.id }}"}},
+			}
+
+			reader, header, err := renderTemplate(desc, c, strings.NewReader(tt.text))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			b, err := io.ReadAll(reader)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, string(b))
+			assert.Equal(t, "/api/people/123", header.Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+)
+
+// throttledWriter writes the response body through a bandwidth limited writer.
+type throttledWriter struct {
+	http.ResponseWriter
+	body io.Writer
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	return w.body.Write(p)
+}
+
+// throttled limits the bandwidth of the writer to the rate of the descriptor, or the server one if it has none.
+func (s *Server) throttled(ctx context.Context, writer http.ResponseWriter, desc *filesystem.Descriptor) http.ResponseWriter {
+	rate := cmp.Or(desc.Throttle, s.throttle)
+	if rate == nil {
+		return writer
+	}
+
+	return &throttledWriter{ResponseWriter: writer, body: rate.Writer(ctx, writer)}
+}
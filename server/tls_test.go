@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/agukrapo/simpler-mock-server/internal/certs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Start_tls(t *testing.T) {
+	cert, ca, err := certs.SelfSigned()
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	s := New(address, &fakeFS{}, WithTLS(&tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		assert.NoError(t, s.Start(ctx))
+	}()
+	defer s.Stop(context.Background())
+
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(ca))
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}}}
+
+	var body []byte
+	require.Eventually(t, func() bool {
+		res, err := client.Get("https://" + address + "/hello")
+		if err != nil {
+			return false
+		}
+		defer res.Body.Close()
+
+		body, err = io.ReadAll(res.Body)
+		return err == nil && res.StatusCode == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Empty(t, body)
+}
+
+func TestServer_Start_tlsClientCert(t *testing.T) {
+	cert, ca, err := certs.SelfSigned()
+	require.NoError(t, err)
+
+	clientCA, clientCAKey := newCert(t, "CA", nil, nil)
+	partner, partnerKey := newCert(t, "partner", clientCA, clientCAKey)
+	stranger, strangerKey := newCert(t, "partner", nil, nil)
+
+	verified, unverified := true, false
+	allowed := descriptor(http.MethodGet, "/api/partners", http.StatusOK, "partners")
+	allowed.MatchClient = &filesystem.ClientMatcher{CN: "partner", Verified: &verified}
+	forbidden := descriptor(http.MethodGet, "/api/partners", http.StatusForbidden, "forbidden")
+	forbidden.MatchClient = &filesystem.ClientMatcher{Verified: &unverified}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA)
+	s := New(address, &fakeFS{descs: []*filesystem.Descriptor{allowed, forbidden}}, WithTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequestClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		assert.NoError(t, s.Start(ctx))
+	}()
+	defer s.Stop(context.Background())
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(ca))
+
+	tests := []struct {
+		name       string
+		cert       *x509.Certificate
+		key        *ecdsa.PrivateKey
+		wantStatus int
+		wantBody   string
+	}{
+		{"signed by the client CA", partner, partnerKey, http.StatusOK, "partners"},
+		{"signed by another CA", stranger, strangerKey, http.StatusForbidden, "forbidden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Go clients only send certificates signed by a CA the server accepts unless told otherwise.
+			clientCert := &tls.Certificate{Certificate: [][]byte{tt.cert.Raw}, PrivateKey: tt.key}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				RootCAs: roots,
+				GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+					return clientCert, nil
+				},
+				MinVersion: tls.VersionTLS12,
+			}}}
+
+			var res *http.Response
+			require.Eventually(t, func() bool {
+				res, err = client.Get("https://" + address + "/api/partners")
+				return err == nil
+			}, time.Second, 10*time.Millisecond)
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			assert.Equal(t, tt.wantBody, string(body))
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	stdmime "mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/agukrapo/simpler-mock-server/internal/mime"
+	"github.com/rs/zerolog/log"
+)
+
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+type upstream struct {
+	base   *url.URL
+	client *http.Client
+}
+
+func newUpstream(base *url.URL) *upstream {
+	return &upstream{
+		base:   base,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// forward sends the call to the upstream server and returns its response, whose body is already read.
+func (u *upstream) forward(c *call) (*http.Response, []byte, error) {
+	target := u.base.JoinPath(c.URL.Path)
+	target.RawQuery = c.URL.RawQuery
+
+	req, err := http.NewRequestWithContext(c.Context(), c.Method, target.String(), c.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("http.NewRequest: %w", err)
+	}
+
+	// the body is streamed, as the reverse proxy does.
+	req.ContentLength = c.ContentLength
+	if c.ContentLength == 0 {
+		req.Body = http.NoBody
+	}
+
+	req.Header = c.Header.Clone()
+	for _, h := range hopHeaders {
+		req.Header.Del(h)
+	}
+	req.Header.Del("Accept-Encoding")
+
+	res, err := u.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("client.Do: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	return res, body, nil
+}
+
+func contentType(res *http.Response) mime.Type {
+	mt, _, err := stdmime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+
+	return mime.Type(mt)
+}
+
+func newProxy(base *url.URL) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(base)
+			r.SetXForwarded()
+		},
+		ErrorHandler: func(writer http.ResponseWriter, req *http.Request, err error) {
+			log.Error().Err(err).Str("request", fmt.Sprintf("%s %s", req.Method, req.URL)).Msg("Proxying request failed")
+			writer.WriteHeader(http.StatusBadGateway)
+		},
+	}
+}
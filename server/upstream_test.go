@@ -0,0 +1,110 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/agukrapo/simpler-mock-server/internal/mime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_record(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "/base/api/people", r.URL.Path)
+		assert.Equal(t, "page=2", r.URL.RawQuery)
+		assert.Equal(t, `{"name":"John"}`, string(b))
+
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("<people/>"))
+	}))
+	defer up.Close()
+
+	base, err := url.Parse(up.URL + "/base")
+	require.NoError(t, err)
+
+	s := newTestServer(t)
+	WithRecordUpstream(base)(s)
+
+	status, body := do(t, s, http.MethodPost, "/api/people?page=2", `{"name":"John"}`)
+	assert.Equal(t, http.StatusTeapot, status)
+	assert.Equal(t, "<people/>", body)
+
+	routes := s.Routes()
+	require.Len(t, routes, 1)
+	assert.Equal(t, "application/xml", string(routes[0]["type"].(mime.Type)))
+}
+
+func TestServer_record_query(t *testing.T) {
+	var calls int
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(r.URL.Query().Get("q")))
+	}))
+	defer up.Close()
+
+	base, err := url.Parse(up.URL)
+	require.NoError(t, err)
+
+	s := newTestServer(t)
+	WithRecordUpstream(base)(s)
+
+	for _, q := range []string{"foo", "bar", "foo"} {
+		_, body := do(t, s, http.MethodGet, "/search?q="+q, "")
+		assert.Equal(t, q, body)
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestServer_proxy(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Upstream", r.URL.Path)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(b)
+	}))
+	defer up.Close()
+
+	base, err := url.Parse(up.URL)
+	require.NoError(t, err)
+
+	s := newTestServer(t, descriptor(http.MethodPost, "/api/mocked", http.StatusOK, "mocked"))
+	WithProxyUpstream(base)(s)
+
+	status, body := do(t, s, http.MethodPost, "/api/mocked", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "mocked", body)
+
+	status, body = do(t, s, http.MethodPost, "/api/real", "echo")
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "echo", body)
+
+	assert.Len(t, s.Routes(), 1)
+}
+
+func TestServer_proxy_largeBody(t *testing.T) {
+	var calls int
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(b)
+	}))
+	defer up.Close()
+
+	base, err := url.Parse(up.URL)
+	require.NoError(t, err)
+
+	s := newTestServer(t)
+	WithProxyUpstream(base)(s)
+
+	status, body := do(t, s, http.MethodPost, "/api/upload", strings.Repeat("a", maxBodySize+1))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, body, maxBodySize+1)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/websocket"
+)
+
+func isWebSocket(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Upgrade"), "websocket")
+}
+
+// upgrade runs the script of the WebSocket route matching the request.
+func (s *Server) upgrade(writer http.ResponseWriter, req *http.Request) {
+	wsReq := req.Clone(req.Context())
+	wsReq.Method = filesystem.WebSocket
+
+	c := newCall(wsReq)
+	if s.tls != nil {
+		c.clientCAs = s.tls.ClientCAs
+	}
+
+	entry := newEntry(c)
+	defer func() {
+		s.journal.add(entry)
+	}()
+
+	desc, ok, err := s.match(c)
+	if err != nil || !ok {
+		log.Error().Err(err).Str("path", req.URL.Path).Msg("Resolving WebSocket route failed")
+		entry.Status = http.StatusNotFound
+		s.fail(writer, c, http.StatusNotFound)
+		return
+	}
+
+	entry.File, entry.Status = desc.Path, desc.Status
+
+	reader, err := desc.Reader()
+	if err != nil {
+		log.Error().Err(err).Fields(fieldsFromDescriptor(desc)).Msg("Reading route failed")
+		s.fail(writer, c, http.StatusNotFound)
+		return
+	}
+	defer reader.Close()
+
+	script, err := filesystem.ParseScript(reader)
+	if err != nil {
+		log.Error().Err(err).Fields(fieldsFromDescriptor(desc)).Msg("Parsing script failed")
+		s.fail(writer, c, http.StatusInternalServerError)
+		return
+	}
+
+	ws := websocket.Server{
+		Handler: func(conn *websocket.Conn) {
+			if err := converse(req.Context(), conn, c, script); err != nil {
+				log.Debug().Err(err).Fields(fieldsFromDescriptor(desc)).Msg("WebSocket closed")
+			}
+		},
+	}
+	ws.ServeHTTP(writer, req)
+}
+
+// converse sends the script messages until the client closes the connection.
+func converse(ctx context.Context, conn *websocket.Conn, c *call, script *filesystem.Script) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	for _, msg := range script.OnConnect {
+		if err := websocket.Message.Send(conn, string(msg)); err != nil {
+			return fmt.Errorf("websocket.Send: %w", err)
+		}
+	}
+
+	for _, p := range script.Pushes {
+		go push(ctx, conn, p)
+	}
+
+	for {
+		var msg []byte
+		if err := websocket.Message.Receive(conn, &msg); err != nil {
+			return fmt.Errorf("websocket.Receive: %w", err)
+		}
+
+		in := &call{Request: c.Request, body: msg}
+		for _, reply := range script.Replies {
+			if !matchBody(reply.Match, in) {
+				continue
+			}
+
+			for _, out := range reply.Send {
+				if err := websocket.Message.Send(conn, string(out)); err != nil {
+					return fmt.Errorf("websocket.Send: %w", err)
+				}
+			}
+			break
+		}
+	}
+}
+
+func push(ctx context.Context, conn *websocket.Conn, p filesystem.Push) {
+	if err := sleep(ctx, p.After); err != nil {
+		return
+	}
+
+	for {
+		if err := websocket.Message.Send(conn, string(p.Send)); err != nil {
+			return
+		}
+
+		if p.Every == 0 {
+			return
+		}
+
+		if err := sleep(ctx, p.Every); err != nil {
+			return
+		}
+	}
+}
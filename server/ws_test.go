@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agukrapo/simpler-mock-server/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func TestServer_upgrade(t *testing.T) {
+	script := descriptor(filesystem.WebSocket, "/chat", http.StatusSwitchingProtocols, `{
+		"onConnect": [{"type": "welcome"}],
+		"replies": [
+			{"match": {"jsonPath": {"$.type": "ping"}}, "send": [{"type": "pong"}, "done"]},
+			{"send": ["unknown"]}
+		],
+		"pushes": [{"after": "10ms", "send": "pushed"}]
+	}`)
+
+	s := newTestServer(t, script)
+	ts := httptest.NewServer(s.s.Handler)
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	conn, err := websocket.Dial(wsURL+"/chat", "", ts.URL)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	receive := func() string {
+		var msg string
+		require.NoError(t, websocket.Message.Receive(conn, &msg))
+		return msg
+	}
+
+	assert.Equal(t, `{"type":"welcome"}`, receive())
+	assert.Equal(t, "pushed", receive())
+
+	require.NoError(t, websocket.Message.Send(conn, `{"type":"ping"}`))
+	assert.Equal(t, `{"type":"pong"}`, receive())
+	assert.Equal(t, "done", receive())
+
+	require.NoError(t, websocket.Message.Send(conn, "what?"))
+	assert.Equal(t, "unknown", receive())
+
+	_, err = websocket.Dial(wsURL+"/missing", "", ts.URL)
+	assert.Error(t, err)
+
+	status, _ := do(t, s, http.MethodGet, "/chat", "")
+	assert.NotEqual(t, http.StatusSwitchingProtocols, status)
+}